@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/muesli/termenv"
+)
+
+// Values of -ansi, whether the local terminal shows escape sequences
+const (
+	ansiAuto = "auto" // enable them on Windows consoles, falling back if that fails
+	ansiOn   = "on"
+	ansiOff  = "off"
+)
+
+// plainOutput is set when the local terminal would show escape sequences as
+// text, so frames and styles are drawn without them
+var plainOutput bool
+
+// asciiSafeFor reports whether to draw with render.ASCIIRamp on a terminal:
+// as -ascii-safe and -no-ascii-safe say, or else if it looks like it may
+// lack the shade blocks. That is when the first of LC_ALL, LC_CTYPE and
+// LANG that is set names a locale other than UTF-8, such as C or POSIX, or
+// when TERM is dumb or a hardware terminal like vt100. With no locale set
+// at all, as is usual on Windows, the terminal is assumed to have them.
+func asciiSafeFor(term string, environ []string) bool {
+	switch {
+	case noASCIISafe:
+		return false
+	case asciiSafeMode:
+		return true
+	}
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(env[name]); locale != "" {
+			if !strings.Contains(locale, "utf-8") && !strings.Contains(locale, "utf8") {
+				return true
+			}
+			break
+		}
+	}
+	return term == "dumb" || strings.HasPrefix(term, "vt")
+}
+
+// setupANSI enables escape sequences on the local terminal as mode asks,
+// setting plainOutput if they can't be used. Modes that only work in color
+// are an error with -ansi off, and turned off with a warning when auto
+// finds the terminal can't show them.
+func setupANSI(mode string) error {
+	if mode == ansiOn || (mode == ansiAuto && enableVirtualTerminal()) {
+		return nil
+	}
+	var ignored []string
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"-halfblock", halfBlockMode},
+		{"-subpixel", subpixelMode},
+		{"-graphics", graphicsMode != ""},
+		{"-crt", crtIntensity > 0 || crtGreen},
+	} {
+		if !flag.set {
+			continue
+		}
+		if mode == ansiOff {
+			return fmt.Errorf("%s needs escape sequences, so it can't be used with -ansi off", flag.name)
+		}
+		ignored = append(ignored, flag.name)
+	}
+	if ignored != nil {
+		log.Warn("the terminal can't show escape sequences, drawing plain ASCII", "ignored", strings.Join(ignored, " "))
+	}
+	halfBlockMode, subpixelMode, graphicsMode = false, false, ""
+	crtIntensity, crtGreen = 0, false
+	plainOutput = true
+	lipgloss.SetColorProfile(termenv.Ascii)
+	return nil
+}
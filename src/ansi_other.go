@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// enableVirtualTerminal reports whether the terminal shows escape
+// sequences, which terminals outside Windows always do
+func enableVirtualTerminal() bool {
+	return true
+}
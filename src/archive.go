@@ -0,0 +1,230 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// frameFS holds the frame set when playing from an archive with
+// -frames-archive, or nil to read frames from disk. Paths in it are
+// relative to frameDir, which is "." while it is set.
+var frameFS fs.FS
+
+// openFrameFile opens a file of the frame set, from the archive if frames
+// are played from one
+func openFrameFile(name string) (fs.File, error) {
+	if frameFS != nil {
+		return frameFS.Open(path.Clean(strings.ReplaceAll(name, `\`, "/")))
+	}
+	return os.Open(name)
+}
+
+// readFrameDir lists a directory of the frame set, from the archive if
+// frames are played from one
+func readFrameDir(dir string) ([]fs.DirEntry, error) {
+	if frameFS != nil {
+		return fs.ReadDir(frameFS, path.Clean(dir))
+	}
+	return os.ReadDir(dir)
+}
+
+// openFrameArchive opens a zip or uncompressed tar archive of frames to read
+// in place, telling them apart by their first bytes. If the archive holds a
+// single folder, the frames are read from inside it. The archive stays open
+// for the life of the process.
+func openFrameArchive(name string) (fs.FS, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s is not an archive: %w", name, err)
+	}
+
+	var fsys fs.FS
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		fsys, err = zip.NewReader(file, info.Size())
+	case bytes.Equal(magic[:2], []byte{0x1f, 0x8b}):
+		// Compressed tars can only be read start to end
+		err = errors.New("gzipped tar archives can't be read in place, use a zip or an uncompressed tar")
+	default:
+		fsys, err = indexTar(file)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading frames archive: %w", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Skip the resource forks macOS adds to zips
+	entries = slices.DeleteFunc(entries, func(e fs.DirEntry) bool { return e.Name() == "__MACOSX" })
+	if len(entries) == 1 && entries[0].IsDir() {
+		if fsys, err = fs.Sub(fsys, entries[0].Name()); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return fsys, nil
+}
+
+// tarFS reads the regular files of an uncompressed tar archive in place,
+// from an index of where each file's data starts
+type tarFS struct {
+	r     io.ReaderAt
+	files map[string]tarEntry
+}
+
+// tarEntry is where a file's data is in the archive
+type tarEntry struct {
+	offset, size int64
+	modTime      time.Time
+}
+
+// indexTar reads the headers of a tar archive, noting where each regular
+// file's data starts
+func indexTar(file *os.File) (*tarFS, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	counter := &countingReader{r: file}
+	tr := tar.NewReader(counter)
+	t := &tarFS{r: file, files: map[string]tarEntry{}}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// tar reads whole headers and no further, so the data is next
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		t.files[name] = tarEntry{offset: counter.n, size: hdr.Size, modTime: hdr.ModTime}
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Open opens a file of the archive
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if e, ok := t.files[name]; ok {
+		return &tarFile{
+			SectionReader: io.NewSectionReader(t.r, e.offset, e.size),
+			info:          tarInfo{name: path.Base(name), size: e.size, modTime: e.modTime},
+		}, nil
+	}
+	if t.isDir(name) {
+		return &tarFile{SectionReader: io.NewSectionReader(t.r, 0, 0), info: tarInfo{name: path.Base(name), dir: true}}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists a directory of the archive. Tars needn't have entries for
+// directories, so they are inferred from the files' paths.
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !t.isDir(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for file, e := range t.files {
+		rest, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		child, _, nested := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		info := tarInfo{name: child, size: e.size, modTime: e.modTime, dir: nested}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// isDir reports whether name is the root or holds any file of the archive
+func (t *tarFS) isDir(name string) bool {
+	if name == "." {
+		return true
+	}
+	for file := range t.files {
+		if strings.HasPrefix(file, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tarFile is an open file of a tarFS
+type tarFile struct {
+	*io.SectionReader
+	info tarInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Close() error               { return nil }
+
+// tarInfo describes a file or inferred directory of a tarFS
+type tarInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i tarInfo) Name() string       { return i.name }
+func (i tarInfo) Size() int64        { return i.size }
+func (i tarInfo) ModTime() time.Time { return i.modTime }
+func (i tarInfo) IsDir() bool        { return i.dir }
+func (i tarInfo) Sys() any           { return nil }
+
+func (i tarInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
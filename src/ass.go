@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseASS parses the dialogue of an ASS/SSA file. Override tags like
+// {\i1} or {\pos(10,20)} are stripped since the player draws its own style;
+// only the line break escapes \N and \n and the hard space \h are honored.
+func ParseASS(r io.Reader) ([]Subtitle, error) {
+	var subtitles []Subtitle
+	scanner := bufio.NewScanner(r)
+	inEvents := false
+	// Field positions, from the section's Format line or the ASS defaults
+	start, end, text := 1, 2, 9
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Format":
+			fields := strings.Split(value, ",")
+			for i, field := range fields {
+				switch strings.TrimSpace(field) {
+				case "Start":
+					start = i
+				case "End":
+					end = i
+				case "Text":
+					text = i
+				}
+			}
+			if text != len(fields)-1 {
+				return nil, fmt.Errorf("ass Format line must end with Text")
+			}
+		case "Dialogue":
+			// Text is last and may itself contain commas
+			fields := strings.SplitN(strings.TrimSpace(value), ",", text+1)
+			if len(fields) != text+1 {
+				return nil, fmt.Errorf("invalid ass dialogue line %q", line)
+			}
+			startTime, err := parseASSTime(fields[start])
+			if err != nil {
+				return nil, err
+			}
+			endTime, err := parseASSTime(fields[end])
+			if err != nil {
+				return nil, err
+			}
+			subtitles = append(subtitles, Subtitle{
+				StartTime: startTime,
+				EndTime:   endTime,
+				Text:      assText(fields[text]),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading ass file: %w", err)
+	}
+
+	// Dialogue lines needn't be in time order
+	sort.SliceStable(subtitles, func(i, j int) bool { return subtitles[i].StartTime < subtitles[j].StartTime })
+	for i := range subtitles {
+		subtitles[i].ID = i + 1
+	}
+	indexSubtitles(subtitles)
+	return subtitles, nil
+}
+
+// parseASSTime parses an ASS time like 0:00:29.08, in centiseconds
+func parseASSTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid ass time %q", s)
+	}
+	h, errH := strconv.Atoi(parts[0])
+	m, errM := strconv.Atoi(parts[1])
+	sec, errS := strconv.ParseFloat(parts[2], 64)
+	if errH != nil || errM != nil || errS != nil {
+		return 0, fmt.Errorf("invalid ass time %q", s)
+	}
+	return time.Hour*time.Duration(h) +
+		time.Minute*time.Duration(m) +
+		time.Duration(sec*float64(time.Second)).Round(10*time.Millisecond), nil
+}
+
+// assText strips override tags from dialogue text and turns its escapes
+// into plain text
+func assText(s string) string {
+	var sb strings.Builder
+	for len(s) > 0 {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			sb.WriteString(s)
+			break
+		}
+		sb.WriteString(s[:open])
+		close := strings.IndexByte(s[open:], '}')
+		if close < 0 {
+			// An unclosed brace is just text
+			sb.WriteString(s[open:])
+			break
+		}
+		s = s[open+close+1:]
+	}
+	return strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(sb.String())
+}
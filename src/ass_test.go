@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseASS(t *testing.T) {
+	// A Format line outside [Events] is ignored, and the events' Format
+	// swaps End before Start and moves both away from their default columns
+	src := "\ufeff[Script Info]\r\n" +
+		"Title: Sample\r\n" +
+		"Format: ignored, because, this, isn't, events\r\n" +
+		"\r\n" +
+		"[Events]\r\n" +
+		"Format: Layer, Style, Name, End, Start, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+		"Comment: 0,Default,,0:00:09.00,0:00:08.00,0,0,0,,not dialogue\r\n" +
+		"Dialogue: 0,Default,,0:00:05.50,0:00:03.25,0,0,0,,{\\i1}Later{\\i0}, with commas, in it\r\n" +
+		"Dialogue: 0,Default,,0:00:02.00,0:00:01.00,0,0,0,,{\\pos(10,20)\\c&H00FF00&}First\\Nline\\nbreak\\hspace\r\n" +
+		"\r\n" +
+		"[Fonts]\r\n" +
+		"Dialogue: not, an, event, here\r\n"
+
+	subs, err := ParseASS(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Subtitle{
+		{ID: 1, StartTime: time.Second, EndTime: 2 * time.Second, Text: "First\nline\nbreak space"},
+		{ID: 2, StartTime: 3250 * time.Millisecond, EndTime: 5500 * time.Millisecond, Text: "Later, with commas, in it"},
+	}
+	if len(subs) != len(want) {
+		t.Fatalf("parsed %d cues, want %d: %+v", len(subs), len(want), subs)
+	}
+	for i, w := range want {
+		got := subs[i]
+		if got.ID != w.ID || got.StartTime != w.StartTime || got.EndTime != w.EndTime || got.Text != w.Text {
+			t.Errorf("cue %d = {%d %v %v %q}, want {%d %v %v %q}", i,
+				got.ID, got.StartTime, got.EndTime, got.Text, w.ID, w.StartTime, w.EndTime, w.Text)
+		}
+	}
+	// Cues are indexed for lookups
+	if got := findSubtitle(subs, 4*time.Second); got != 1 {
+		t.Errorf("findSubtitle(4s) = %d, want 1", got)
+	}
+}
+
+func TestParseASSDefaultFormat(t *testing.T) {
+	// Without a Format line the ASS default field order is assumed
+	src := "[Events]\nDialogue: 0,0:00:01.00,0:01:02.03,Default,,0,0,0,,Hello\n"
+	subs, err := ParseASS(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].StartTime != time.Second || subs[0].EndTime != 62030*time.Millisecond || subs[0].Text != "Hello" {
+		t.Errorf("parsed %+v, want Hello from 1s to 1m2.03s", subs)
+	}
+}
+
+func TestParseASSErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"Text not last": "[Events]\nFormat: Start, End, Text, Style\n",
+		"bad time":      "[Events]\nDialogue: 0,0:00:xx.00,0:00:02.00,Default,,0,0,0,,Hi\n",
+		"short line":    "[Events]\nDialogue: 0,0:00:01.00\n",
+	} {
+		if _, err := ParseASS(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: parsed without an error", name)
+		}
+	}
+}
+
+func TestASSText(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{`plain`, "plain"},
+		{`{\b1}bold{\b0} and {\fs20\blur2}big`, "bold and big"},
+		{`one\Ntwo\nthree`, "one\ntwo\nthree"},
+		{`hard\hspace`, "hard space"},
+		{`{unclosed tag`, "{unclosed tag"},
+		{`a{}b`, "ab"},
+	} {
+		if got := assText(tt.in); got != tt.want {
+			t.Errorf("assText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
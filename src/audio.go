@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"sync"
 	"time"
@@ -10,23 +14,116 @@ import (
 	"github.com/hajimehoshi/go-mp3"
 )
 
+// Bytes per sample frame. go-mp3 always decodes to 16-bit stereo,
+// duplicating the channel of mono files.
+const audioFrameSize = 4
+
+// AudioController is the audio playback the player drives. AudioPlayer
+// plays through the sound card; a stand-in keeping time without a device,
+// such as a fake for tests, can take its place by implementing it.
+type AudioController interface {
+	Play()
+	Pause()
+	Resume()
+	Stop()
+	Close()
+	IsPaused() bool
+	// Position returns how far into the audio playback has reached
+	Position() time.Duration
+	Seek(pos time.Duration) error
+	SetVolume(volume float64)
+	// Levels and Onset measure the audio around pos for the visualizer and
+	// beat detection
+	Levels(pos time.Duration, n int) []float64
+	Onset(pos time.Duration) bool
+	// SetOnStall sets a function called with the playback position when
+	// audio stops advancing while playing. Set it before calling Play. It
+	// isn't called once Close returns, and must not block.
+	SetOnStall(func(pos time.Duration))
+}
+
+// newAudio opens audioFile for the player. It is a variable so audio can be
+// swapped for an AudioController that doesn't need a sound card.
+var newAudio = func() (AudioController, error) {
+	ap, err := NewAudioPlayer()
+	if err != nil {
+		// Not a nil *AudioPlayer, which would be a non-nil interface
+		return nil, err
+	}
+	return ap, nil
+}
+
 // AudioPlayer manages audio playback with pause/resume functionality
 type AudioPlayer struct {
-	player     *oto.Player
-	context    *oto.Context
-	decoder    *mp3.Decoder
-	file       *os.File
+	player  *oto.Player
+	context *oto.Context
+	decoder *mp3.Decoder
+	file    *os.File
+	// Sample rate of the decoded audio, which the oto context plays at
+	sampleRate int
+	levels     *levelReader
+	volume     float64
 	playing    bool
 	paused     bool
+	closed     bool
 	mu         sync.Mutex
-	stopChan   chan struct{}
-	resumeChan chan struct{}
+	// ctx is cancelled by Close, stopMonitor by Stop. Either ends the
+	// playback monitor goroutine.
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopMonitor context.CancelFunc
+
+	// onStall, if set, is called from the monitor goroutine with the
+	// playback position when audio stops advancing while playing, such as
+	// after an underrun. It is called holding mu, so it is never called
+	// after Close returns.
+	onStall func(pos time.Duration)
+}
+
+// stallTimeout is how long playback may stop advancing before it counts as
+// stalled
+const stallTimeout = 500 * time.Millisecond
+
+// audioFile is the MP3 played with the video, changed by -playlist
+var audioFile = "bad_apple.mp3"
+
+// oto allows one context per process, so every player shares the first one
+// and must play at its sample rate
+var (
+	otoMu      sync.Mutex
+	sharedOto  *oto.Context
+	sharedRate int
+)
+
+// sharedContext returns the process's oto context, creating it at
+// sampleRate the first time
+func sharedContext(sampleRate int) (*oto.Context, error) {
+	otoMu.Lock()
+	defer otoMu.Unlock()
+	if sharedOto != nil {
+		if sampleRate != sharedRate {
+			return nil, fmt.Errorf("audio at %d Hz can't play after audio at %d Hz", sampleRate, sharedRate)
+		}
+		return sharedOto, nil
+	}
+	ctx, readyChan, err := oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   sampleRate,
+		ChannelCount: 2,
+		Format:       oto.FormatSignedInt16LE,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error initializing oto: %w", err)
+	}
+	// Wait for the audio context to be ready
+	<-readyChan
+	sharedOto, sharedRate = ctx, sampleRate
+	return ctx, nil
 }
 
 // NewAudioPlayer creates a new audio player
 func NewAudioPlayer() (*AudioPlayer, error) {
 	// Open the MP3 file
-	file, err := os.Open("bad_apple.mp3")
+	file, err := os.Open(audioFile)
 	if err != nil {
 		return nil, fmt.Errorf("error opening audio file: %w", err)
 	}
@@ -38,33 +135,42 @@ func NewAudioPlayer() (*AudioPlayer, error) {
 		return nil, fmt.Errorf("error decoding MP3: %w", err)
 	}
 
-	// Initialize oto
-	otoCtx, readyChan, err := oto.NewContext(&oto.NewContextOptions{
-		SampleRate:   44100,
-		ChannelCount: 2,
-		Format:       oto.FormatSignedInt16LE,
-	})
+	// Play at the file's own rate so it plays at the right speed
+	sampleRate := decoder.SampleRate()
+	otoCtx, err := sharedContext(sampleRate)
 	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("error initializing oto: %w", err)
+		return nil, err
 	}
 
-	// Wait for the audio context to be ready
-	<-readyChan
+	// Create a player, measuring levels as audio is decoded
+	levels := &levelReader{src: pcmSource(decoder, sampleRate)}
+	player := otoCtx.NewPlayer(levels)
 
-	// Create a player
-	player := otoCtx.NewPlayer(decoder)
-
-	return &AudioPlayer{
+	ctx, cancel := context.WithCancel(context.Background())
+	ap := &AudioPlayer{
 		player:     player,
 		context:    otoCtx,
 		decoder:    decoder,
 		file:       file,
+		sampleRate: sampleRate,
+		levels:     levels,
+		volume:     1,
 		playing:    false,
 		paused:     false,
-		stopChan:   make(chan struct{}),
-		resumeChan: make(chan struct{}),
-	}, nil
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	openAudio.Store(ap, struct{}{})
+	return ap, nil
+}
+
+// pcmSource returns the decoded audio to play, tilted if -audio-tilt is set
+func pcmSource(decoder *mp3.Decoder, sampleRate int) io.ReadSeeker {
+	if audioTilt == 0 {
+		return decoder
+	}
+	return newTiltFilter(decoder, audioTilt, sampleRate)
 }
 
 // Play starts audio playback
@@ -72,7 +178,7 @@ func (ap *AudioPlayer) Play() {
 	ap.mu.Lock()
 	defer ap.mu.Unlock()
 
-	if ap.playing {
+	if ap.playing || ap.closed {
 		return
 	}
 
@@ -81,7 +187,9 @@ func (ap *AudioPlayer) Play() {
 	ap.player.Play()
 
 	// Start playback monitoring in a goroutine
-	go ap.monitorPlayback()
+	var monitorCtx context.Context
+	monitorCtx, ap.stopMonitor = context.WithCancel(ap.ctx)
+	go ap.monitorPlayback(monitorCtx)
 }
 
 // Pause pauses audio playback
@@ -108,7 +216,6 @@ func (ap *AudioPlayer) Resume() {
 
 	ap.paused = false
 	ap.player.Play()
-	ap.resumeChan <- struct{}{}
 }
 
 // Stop stops audio playback and resets to beginning
@@ -116,13 +223,17 @@ func (ap *AudioPlayer) Stop() {
 	ap.mu.Lock()
 	defer ap.mu.Unlock()
 
-	if !ap.playing {
+	if ap.closed {
 		return
 	}
 
+	// Rewind even when playback already finished on its own, so the
+	// audio can be restarted when the video loops
+	if ap.stopMonitor != nil {
+		ap.stopMonitor()
+	}
 	ap.playing = false
 	ap.paused = false
-	ap.stopChan <- struct{}{}
 
 	// Close current player and create a new one
 	ap.player.Close()
@@ -134,7 +245,90 @@ func (ap *AudioPlayer) Stop() {
 	if err != nil {
 		return
 	}
-	ap.player = ap.context.NewPlayer(ap.decoder)
+	ap.levels.reset(pcmSource(ap.decoder, ap.sampleRate))
+	ap.player = ap.context.NewPlayer(ap.levels)
+	ap.player.SetVolume(ap.volume)
+}
+
+// SetVolume sets the playback volume from 0 (silent) to 1 (full)
+func (ap *AudioPlayer) SetVolume(volume float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.closed {
+		return
+	}
+	ap.volume = volume
+	ap.player.SetVolume(volume)
+}
+
+// Seek moves playback to the given position from the start of the audio
+func (ap *AudioPlayer) Seek(pos time.Duration) error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	offset := int64(pos.Seconds()*float64(ap.sampleRate)) * audioFrameSize
+	if _, err := ap.player.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking audio: %w", err)
+	}
+	return nil
+}
+
+// Position returns how far into the audio playback has reached, not
+// counting audio decoded but still buffered
+func (ap *AudioPlayer) Position() time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.closed {
+		return 0
+	}
+	return ap.bytesDuration(ap.playedBytes())
+}
+
+// SetOnStall sets the function called when playback stalls
+func (ap *AudioPlayer) SetOnStall(onStall func(pos time.Duration)) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.onStall = onStall
+}
+
+// Levels returns the loudness of the n level windows leading up to pos,
+// oldest first, from 0 (silent) to 1. Windows not decoded yet are 0.
+func (ap *AudioPlayer) Levels(pos time.Duration, n int) []float64 {
+	end := ap.levelIndex(pos)
+	return ap.levels.window(end-n, end)
+}
+
+// Onset detection: a window is an onset when it is loud enough and louder
+// than the recent average by onsetRatio
+const (
+	onsetHistory  = 32 // windows averaged before a candidate, ~370ms
+	onsetHold     = 8  // windows an onset stays reported, ~90ms
+	onsetRatio    = 1.6
+	onsetMinLevel = 0.05
+)
+
+// Onset reports whether an energy onset happened shortly before pos
+func (ap *AudioPlayer) Onset(pos time.Duration) bool {
+	end := ap.levelIndex(pos)
+	levels := ap.levels.window(end-onsetHistory-onsetHold, end)
+	for i := onsetHistory; i < len(levels); i++ {
+		mean := 0.0
+		for _, level := range levels[i-onsetHistory : i] {
+			mean += level
+		}
+		mean /= onsetHistory
+		if levels[i] >= onsetMinLevel && levels[i] > mean*onsetRatio {
+			return true
+		}
+	}
+	return false
+}
+
+// levelIndex returns the level window playing at pos
+func (ap *AudioPlayer) levelIndex(pos time.Duration) int {
+	return int(pos.Seconds() * float64(ap.sampleRate) * audioFrameSize / levelWindow)
 }
 
 // IsPlaying returns true if audio is currently playing
@@ -151,14 +345,18 @@ func (ap *AudioPlayer) IsPaused() bool {
 	return ap.playing && ap.paused
 }
 
-// Close cleans up resources
+// Close cleans up resources. It is safe to call more than once.
 func (ap *AudioPlayer) Close() {
 	ap.mu.Lock()
 	defer ap.mu.Unlock()
 
-	if ap.playing {
-		ap.stopChan <- struct{}{}
+	if ap.closed {
+		return
 	}
+	ap.closed = true
+	openAudio.Delete(ap)
+	ap.cancel()
+	ap.playing = false
 
 	if ap.player != nil {
 		ap.player.Close()
@@ -169,30 +367,144 @@ func (ap *AudioPlayer) Close() {
 	// Note: oto.Context doesn't have a Close method, it's managed by the library
 }
 
-// monitorPlayback monitors the audio playback and handles completion
-func (ap *AudioPlayer) monitorPlayback() {
+// monitorPlayback marks playback as finished once the player runs out of
+// audio, and reports stalls where the position stops advancing. It exits
+// when ctx is cancelled.
+func (ap *AudioPlayer) monitorPlayback(ctx context.Context) {
+	defer recoverPanic()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastPlayed := int64(-1)
+	lastProgress := time.Now()
+	stalled := false
 	for {
 		select {
-		case <-ap.stopChan:
+		case <-ctx.Done():
 			return
-		case <-ap.resumeChan:
-			// Continue monitoring after resume
-		default:
-			ap.mu.Lock()
-			if !ap.playing || ap.paused {
-				ap.mu.Unlock()
-				time.Sleep(100 * time.Millisecond)
-				continue
-			}
+		case <-ticker.C:
+		}
+
+		ap.mu.Lock()
+		if !ap.playing || ap.paused {
+			// Time spent paused doesn't count towards a stall
+			lastProgress = time.Now()
+			ap.mu.Unlock()
+			continue
+		}
+		if !ap.player.IsPlaying() {
+			ap.playing = false
+			ap.mu.Unlock()
+			return
+		}
 
-			if !ap.player.IsPlaying() {
-				ap.playing = false
-				ap.mu.Unlock()
-				return
+		played := ap.playedBytes()
+		if played != lastPlayed {
+			lastPlayed = played
+			lastProgress = time.Now()
+			stalled = false
+		} else if !stalled && time.Since(lastProgress) > stallTimeout {
+			stalled = true
+			// Re-prime the player and let the video catch up
+			ap.player.Play()
+			if ap.onStall != nil {
+				ap.onStall(ap.bytesDuration(played))
 			}
 			ap.mu.Unlock()
+			continue
+		}
+		ap.mu.Unlock()
+	}
+}
+
+// playedBytes returns the stream offset the player has reached: what has
+// been decoded minus what is still buffered. Callers must hold ap.mu.
+func (ap *AudioPlayer) playedBytes() int64 {
+	return ap.levels.position() - int64(ap.player.BufferedSize())
+}
+
+// bytesDuration returns how long n bytes of decoded audio play
+func (ap *AudioPlayer) bytesDuration(n int64) time.Duration {
+	return time.Duration(float64(n) / float64(ap.sampleRate*audioFrameSize) * float64(time.Second))
+}
+
+// Bytes of decoded audio measured for each level, ~12ms at 44.1kHz
+const levelWindow = 2048
+
+// levelReader passes decoded audio through to the player, recording the RMS
+// level of each window of samples by its position in the stream
+type levelReader struct {
+	src    io.ReadSeeker
+	mu     sync.Mutex
+	pos    int64   // stream offset of the next byte read
+	sum    float64 // sum of squares for the current window
+	levels []float64
+}
+
+// Read reads 16-bit samples from the source and measures them. The lock
+// is held while reading, as oto may read from a new play goroutine before
+// the one a Pause left behind has returned.
+func (lr *levelReader) Read(p []byte) (int, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	n, err := lr.src.Read(p)
+	for i := 0; i+1 < n; i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(p[i:]))) / math.MaxInt16
+		lr.sum += v * v
+		lr.pos += 2
+		if lr.pos%levelWindow == 0 {
+			index := int(lr.pos/levelWindow) - 1
+			for len(lr.levels) <= index {
+				lr.levels = append(lr.levels, 0)
+			}
+			lr.levels[index] = math.Min(1, math.Sqrt(lr.sum/(levelWindow/2)))
+			lr.sum = 0
+		}
+	}
+	return n, err
+}
+
+// Seek seeks the source, so levels keep matching stream positions
+func (lr *levelReader) Seek(offset int64, whence int) (int64, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	pos, err := lr.src.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	lr.pos = pos
+	lr.sum = 0
+	return pos, nil
+}
+
+// position returns the stream offset of the next byte read
+func (lr *levelReader) position() int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.pos
+}
+
+// reset switches to a new source positioned at the start of the stream.
+// Levels already measured are kept since they describe the same audio.
+func (lr *levelReader) reset(src io.ReadSeeker) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	lr.src = src
+	lr.pos = 0
+	lr.sum = 0
+}
+
+// window returns the levels for windows [start, end), with 0 for windows
+// outside the measured range
+func (lr *levelReader) window(start, end int) []float64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
 
-			time.Sleep(100 * time.Millisecond)
+	out := make([]float64, max(0, end-start))
+	for i := range out {
+		if index := start + i; index >= 0 && index < len(lr.levels) {
+			out[i] = lr.levels[index]
 		}
 	}
+	return out
 }
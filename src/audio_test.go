@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// useSilentAudio writes a second of silent MP3 and points audioFile at it.
+// Each frame is a 32 kbps 44.1 kHz mono header with no audio data.
+func useSilentAudio(t *testing.T) {
+	t.Helper()
+	frame := make([]byte, 104)
+	copy(frame, []byte{0xff, 0xfb, 0x10, 0xc0})
+	path := filepath.Join(t.TempDir(), "silence.mp3")
+	if err := os.WriteFile(path, bytes.Repeat(frame, 40), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := audioFile
+	t.Cleanup(func() { audioFile = old })
+	audioFile = path
+}
+
+// newTestAudio opens the silent audio, skipping the test without a sound
+// device to play it on
+func newTestAudio(t *testing.T) *AudioPlayer {
+	t.Helper()
+	ap, err := NewAudioPlayer()
+	if err != nil {
+		t.Skipf("no audio output: %v", err)
+	}
+	return ap
+}
+
+func TestAudioPlayerCloseLeavesNoGoroutines(t *testing.T) {
+	useSilentAudio(t)
+	// The shared oto context lives for the process, so open it before
+	// taking the goroutines that are allowed to remain
+	newTestAudio(t).Close()
+	ignore := goleak.IgnoreCurrent()
+
+	for name, use := range map[string]func(ap *AudioPlayer){
+		"never played": func(ap *AudioPlayer) {},
+		"playing":      func(ap *AudioPlayer) { ap.Play() },
+		"paused": func(ap *AudioPlayer) {
+			ap.Play()
+			ap.Pause()
+		},
+		"resumed": func(ap *AudioPlayer) {
+			ap.Play()
+			ap.Pause()
+			ap.Resume()
+		},
+		"stopped and replayed": func(ap *AudioPlayer) {
+			ap.Play()
+			ap.Stop()
+			ap.Play()
+		},
+		"played to the end": func(ap *AudioPlayer) {
+			ap.Play()
+			time.Sleep(1500 * time.Millisecond)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ap := newTestAudio(t)
+			use(ap)
+			ap.Close()
+			// A second Close must not block or panic
+			ap.Close()
+			goleak.VerifyNone(t, ignore)
+		})
+	}
+}
+
+// fakeAudio is an AudioController that keeps no time of its own, recording
+// the calls the player makes. Its position only moves when seeked or set.
+type fakeAudio struct {
+	calls   []string
+	pos     time.Duration
+	playing bool
+	paused  bool
+	closed  bool
+	onStall func(pos time.Duration)
+}
+
+func (fa *fakeAudio) Play() {
+	fa.calls = append(fa.calls, "play")
+	fa.playing, fa.paused = true, false
+}
+
+func (fa *fakeAudio) Pause() {
+	fa.calls = append(fa.calls, "pause")
+	fa.paused = fa.playing
+}
+
+func (fa *fakeAudio) Resume() {
+	fa.calls = append(fa.calls, "resume")
+	fa.paused = false
+}
+
+func (fa *fakeAudio) Stop() {
+	fa.calls = append(fa.calls, "stop")
+	fa.playing, fa.paused, fa.pos = false, false, 0
+}
+
+func (fa *fakeAudio) Close()                  { fa.closed = true }
+func (fa *fakeAudio) IsPaused() bool          { return fa.playing && fa.paused }
+func (fa *fakeAudio) Position() time.Duration { return fa.pos }
+
+func (fa *fakeAudio) Seek(pos time.Duration) error {
+	fa.calls = append(fa.calls, fmt.Sprintf("seek %v", pos))
+	fa.pos = pos
+	return nil
+}
+
+func (fa *fakeAudio) SetVolume(volume float64)                   {}
+func (fa *fakeAudio) Levels(pos time.Duration, n int) []float64  { return make([]float64, n) }
+func (fa *fakeAudio) Onset(pos time.Duration) bool               { return false }
+func (fa *fakeAudio) SetOnStall(onStall func(pos time.Duration)) { fa.onStall = onStall }
+
+// takeCalls returns the calls made since the last time it was called
+func (fa *fakeAudio) takeCalls() []string {
+	calls := fa.calls
+	fa.calls = nil
+	return calls
+}
+
+// useFakeAudio makes the player open a fakeAudio in place of the sound card
+func useFakeAudio(t *testing.T) *fakeAudio {
+	t.Helper()
+	fa := &fakeAudio{}
+	oldNew, oldFile := newAudio, audioFile
+	t.Cleanup(func() { newAudio, audioFile = oldNew, oldFile })
+	newAudio = func() (AudioController, error) { return fa, nil }
+	// Audio only starts with a file to play, which the fake never opens
+	audioFile = "fake.mp3"
+	return fa
+}
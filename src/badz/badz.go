@@ -0,0 +1,258 @@
+// Package badz reads and writes frame packs: a whole grayscale video in one
+// compact file. Bad Apple is mostly flat black and white, and consecutive
+// frames differ in few pixels, so storing each frame as a run-length encoded
+// XOR against the previous one is far smaller than a directory of PNGs.
+//
+// A pack is a header followed by every frame, with integers big endian:
+//
+//	header:  "BADZ" | version u8 (1) | width u16 | height u16 | frames u32
+//	frame:   kind u8 (0 keyframe, 1 delta) | size u32 | payload[size]
+//	payload: runs of (length uvarint, value u8) covering width*height bytes
+//
+// A keyframe's runs are its pixels, row by row. A delta's runs are XORed with
+// the previous frame's pixels, so unchanged pixels are long runs of zero.
+// Keyframes are written at a fixed interval so a frame can be decoded without
+// reading the whole pack.
+package badz
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	magic   = "BADZ"
+	version = 1
+
+	headerSize      = 13
+	frameHeaderSize = 5
+
+	kindKey   = 0
+	kindDelta = 1
+)
+
+// Writer encodes frames into a pack
+type Writer struct {
+	w           io.Writer
+	width       int
+	height      int
+	frames      int
+	written     int
+	keyInterval int
+	prev        []byte
+}
+
+// NewWriter writes the header of a pack holding frames frames of width x
+// height, with a keyframe every keyInterval frames
+func NewWriter(w io.Writer, width, height, frames, keyInterval int) (*Writer, error) {
+	if width <= 0 || width > 0xffff || height <= 0 || height > 0xffff {
+		return nil, fmt.Errorf("frame size %dx%d doesn't fit a pack", width, height)
+	}
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = binary.BigEndian.AppendUint16(header, uint16(width))
+	header = binary.BigEndian.AppendUint16(header, uint16(height))
+	header = binary.BigEndian.AppendUint32(header, uint32(frames))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, width: width, height: height, frames: frames, keyInterval: max(1, keyInterval)}, nil
+}
+
+// WriteFrame encodes the next frame, which must have the pack's size
+func (pw *Writer) WriteFrame(img *image.Gray) error {
+	b := img.Bounds()
+	if b.Dx() != pw.width || b.Dy() != pw.height {
+		return fmt.Errorf("frame %d is %dx%d, not %dx%d", pw.written+1, b.Dx(), b.Dy(), pw.width, pw.height)
+	}
+	if pw.written == pw.frames {
+		return fmt.Errorf("pack already holds %d frames", pw.frames)
+	}
+
+	// Copy the pixels out, the image may be a sub-image with a wider stride
+	pix := make([]byte, pw.width*pw.height)
+	for y := range pw.height {
+		copy(pix[y*pw.width:(y+1)*pw.width], img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):])
+	}
+
+	kind := byte(kindKey)
+	values := pix
+	if pw.written%pw.keyInterval != 0 {
+		kind = kindDelta
+		values = make([]byte, len(pix))
+		for i := range pix {
+			values[i] = pix[i] ^ pw.prev[i]
+		}
+	}
+	payload := encodeRuns(values)
+
+	frame := make([]byte, 0, frameHeaderSize+len(payload))
+	frame = append(frame, kind)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	if _, err := pw.w.Write(frame); err != nil {
+		return err
+	}
+	pw.prev = pix
+	pw.written++
+	return nil
+}
+
+// Close checks that every frame promised in the header was written
+func (pw *Writer) Close() error {
+	if pw.written != pw.frames {
+		return fmt.Errorf("pack holds %d of %d frames", pw.written, pw.frames)
+	}
+	return nil
+}
+
+// encodeRuns run-length encodes values
+func encodeRuns(values []byte) []byte {
+	var out []byte
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i))
+		out = append(out, values[i])
+		i = j
+	}
+	return out
+}
+
+// decodeRuns decodes runs into dst, XORing them in for deltas
+func decodeRuns(dst, payload []byte, xor bool) error {
+	n := 0
+	for len(payload) > 0 {
+		length, size := binary.Uvarint(payload)
+		if size <= 0 || size >= len(payload) || length > uint64(len(dst)-n) {
+			return errors.New("corrupt frame")
+		}
+		value := payload[size]
+		payload = payload[size+1:]
+		run := dst[n : n+int(length)]
+		for i := range run {
+			if xor {
+				run[i] ^= value
+			} else {
+				run[i] = value
+			}
+		}
+		n += int(length)
+	}
+	if n != len(dst) {
+		return errors.New("corrupt frame")
+	}
+	return nil
+}
+
+// Reader decodes frames from a pack held in memory. It's safe for
+// concurrent use.
+type Reader struct {
+	data    []byte
+	width   int
+	height  int
+	offsets []int // start of each frame's header
+	keys    []int // index of the keyframe each frame is decoded from
+
+	mu        sync.Mutex
+	lastIndex int // index of the frame in last, or -1
+	last      []byte
+}
+
+// Open reads a pack file and indexes its frames
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(data)
+}
+
+// NewReader indexes the frames of a pack
+func NewReader(data []byte) (*Reader, error) {
+	if len(data) < headerSize || string(data[:4]) != magic {
+		return nil, errors.New("not a frame pack")
+	}
+	if data[4] != version {
+		return nil, fmt.Errorf("unsupported frame pack version %d", data[4])
+	}
+	r := &Reader{
+		data:      data,
+		width:     int(binary.BigEndian.Uint16(data[5:])),
+		height:    int(binary.BigEndian.Uint16(data[7:])),
+		lastIndex: -1,
+	}
+	frames := int(binary.BigEndian.Uint32(data[9:]))
+
+	offset, key := headerSize, -1
+	for i := range frames {
+		if offset+frameHeaderSize > len(data) {
+			return nil, fmt.Errorf("frame pack is truncated at frame %d", i+1)
+		}
+		switch data[offset] {
+		case kindKey:
+			key = i
+		case kindDelta:
+			if key < 0 {
+				return nil, errors.New("frame pack doesn't start with a keyframe")
+			}
+		default:
+			return nil, fmt.Errorf("frame %d has unknown kind %d", i+1, data[offset])
+		}
+		r.offsets = append(r.offsets, offset)
+		r.keys = append(r.keys, key)
+		offset += frameHeaderSize + int(binary.BigEndian.Uint32(data[offset+1:]))
+	}
+	if offset > len(data) {
+		return nil, fmt.Errorf("frame pack is truncated at frame %d", frames)
+	}
+	return r, nil
+}
+
+// Len returns the number of frames in the pack
+func (r *Reader) Len() int {
+	return len(r.offsets)
+}
+
+// Size returns the width and height of the frames
+func (r *Reader) Size() (int, int) {
+	return r.width, r.height
+}
+
+// Frame decodes frame i, counting from 0. Decoding starts from the last
+// frame decoded when playing forward, or else from the nearest keyframe.
+func (r *Reader) Frame(i int) (*image.Gray, error) {
+	if i < 0 || i >= len(r.offsets) {
+		return nil, fmt.Errorf("frame %d is outside the pack's %d frames", i+1, len(r.offsets))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.keys[i]
+	pix := make([]byte, r.width*r.height)
+	if r.lastIndex >= start && r.lastIndex <= i {
+		start = r.lastIndex + 1
+		copy(pix, r.last)
+	}
+	for j := start; j <= i; j++ {
+		offset := r.offsets[j]
+		size := int(binary.BigEndian.Uint32(r.data[offset+1:]))
+		payload := r.data[offset+frameHeaderSize : offset+frameHeaderSize+size]
+		if err := decodeRuns(pix, payload, r.data[offset] == kindDelta); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", j+1, err)
+		}
+	}
+	r.lastIndex, r.last = i, pix
+
+	img := image.NewGray(image.Rect(0, 0, r.width, r.height))
+	copy(img.Pix, pix)
+	return img, nil
+}
@@ -0,0 +1,171 @@
+package badz
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// grayFrame returns a w x h frame with pixels from fill
+func grayFrame(w, h int, fill func(x, y int) uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Pix[y*img.Stride+x] = fill(x, y)
+		}
+	}
+	return img
+}
+
+// roundTrip writes frames to a pack with a keyframe every keyInterval frames
+// and reads it back, checking every frame decodes to the same pixels both
+// playing forward and seeking backward
+func roundTrip(t *testing.T, frames []*image.Gray, keyInterval int) []byte {
+	t.Helper()
+	b := frames[0].Bounds()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, b.Dx(), b.Dy(), len(frames), keyInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		if err := w.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != len(frames) {
+		t.Fatalf("Len() = %d, want %d", r.Len(), len(frames))
+	}
+	if w, h := r.Size(); w != b.Dx() || h != b.Dy() {
+		t.Fatalf("Size() = %dx%d, want %dx%d", w, h, b.Dx(), b.Dy())
+	}
+	check := func(i int) {
+		t.Helper()
+		got, err := r.Frame(i)
+		if err != nil {
+			t.Fatalf("Frame(%d): %v", i, err)
+		}
+		want := grayFrame(b.Dx(), b.Dy(), func(x, y int) uint8 { return frames[i].GrayAt(b.Min.X+x, b.Min.Y+y).Y })
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("frame %d decoded to %v, want %v", i, got.Pix, want.Pix)
+		}
+	}
+	for i := range frames {
+		check(i)
+	}
+	for i := len(frames) - 1; i >= 0; i-- {
+		check(i)
+	}
+	return buf.Bytes()
+}
+
+// frameKinds returns the kind of each frame in a pack
+func frameKinds(t *testing.T, pack []byte) []byte {
+	t.Helper()
+	r, err := NewReader(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make([]byte, len(r.offsets))
+	for i, offset := range r.offsets {
+		kinds[i] = pack[offset]
+	}
+	return kinds
+}
+
+func TestKeyframesOnly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var frames []*image.Gray
+	for range 5 {
+		frames = append(frames, grayFrame(16, 12, func(x, y int) uint8 { return uint8(rng.Intn(256)) }))
+	}
+	pack := roundTrip(t, frames, 1)
+	for i, kind := range frameKinds(t, pack) {
+		if kind != kindKey {
+			t.Errorf("frame %d has kind %d with a keyframe every frame", i, kind)
+		}
+	}
+}
+
+func TestDeltas(t *testing.T) {
+	// A bar moving across a black frame, like Bad Apple's flat shapes
+	var frames []*image.Gray
+	for i := range 10 {
+		frames = append(frames, grayFrame(32, 24, func(x, y int) uint8 {
+			if x >= i*3 && x < i*3+4 {
+				return 255
+			}
+			return 0
+		}))
+	}
+	pack := roundTrip(t, frames, 4)
+	want := []byte{kindKey, kindDelta, kindDelta, kindDelta, kindKey, kindDelta, kindDelta, kindDelta, kindKey, kindDelta}
+	if kinds := frameKinds(t, pack); !bytes.Equal(kinds, want) {
+		t.Errorf("frame kinds = %v, want %v", kinds, want)
+	}
+}
+
+func TestEmptyDelta(t *testing.T) {
+	still := grayFrame(20, 10, func(x, y int) uint8 { return uint8(x * y) })
+	pack := roundTrip(t, []*image.Gray{still, still, still}, 10)
+
+	// An unchanged frame is a single run of zeros
+	r, err := NewReader(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 3; i++ {
+		offset := r.offsets[i]
+		if size := pack[offset+1 : offset+frameHeaderSize]; !bytes.Equal(size, []byte{0, 0, 0, 3}) {
+			t.Errorf("unchanged frame %d payload size = %v, want 3 bytes", i, size)
+		}
+	}
+}
+
+func TestOddSizes(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, size := range [][2]int{{1, 1}, {7, 5}, {13, 3}} {
+		var frames []*image.Gray
+		for range 4 {
+			frames = append(frames, grayFrame(size[0], size[1], func(x, y int) uint8 { return uint8(rng.Intn(3) * 127) }))
+		}
+		roundTrip(t, frames, 3)
+	}
+
+	// A sub-image has a stride wider than its frames
+	big := grayFrame(10, 10, func(x, y int) uint8 { return uint8(x*10 + y) })
+	sub := big.SubImage(image.Rect(3, 2, 8, 9)).(*image.Gray)
+	roundTrip(t, []*image.Gray{sub, sub}, 2)
+}
+
+func TestCorruptPacks(t *testing.T) {
+	frame := grayFrame(4, 4, func(x, y int) uint8 { return uint8(x) })
+	pack := roundTrip(t, []*image.Gray{frame, frame}, 2)
+
+	if _, err := NewReader(pack[:len(pack)-1]); err == nil {
+		t.Error("NewReader accepted a truncated pack")
+	}
+	if _, err := NewReader(append([]byte("PNG!"), pack[4:]...)); err == nil {
+		t.Error("NewReader accepted a pack without the magic")
+	}
+
+	// A run longer than the frame
+	bad := bytes.Clone(pack)
+	bad[headerSize+frameHeaderSize] = 0x7f
+	r, err := NewReader(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Frame(0); err == nil {
+		t.Error("Frame decoded a run past the end of the frame")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// borderNone draws the video without a border
+const borderNone = "none"
+
+// borders maps -border names to their box-drawing characters
+var borders = map[string]lipgloss.Border{
+	"rounded": lipgloss.RoundedBorder(),
+	"square":  lipgloss.NormalBorder(),
+	"double":  lipgloss.DoubleBorder(),
+	"ascii":   lipgloss.ASCIIBorder(),
+}
+
+// borderNames returns the valid -border values, sorted
+func borderNames() []string {
+	names := []string{borderNone}
+	for name := range borders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// borderSize returns the columns and rows the border takes around the video
+func (m *Model) borderSize() (int, int) {
+	if m.border == borderNone {
+		return 0, 0
+	}
+	return 2, 2
+}
+
+// withBorder draws the border around the video, with the title set into the
+// top edge. Lines narrower than the video, like letterboxed frames, are
+// padded so the right edge lines up.
+func (m Model) withBorder(video string) string {
+	b, ok := borders[m.border]
+	if !ok {
+		return video
+	}
+	edge := m.theme.Controls
+
+	top := ""
+	if m.borderTitle != "" {
+		top = b.Top + " " + ansi.Truncate(m.borderTitle, max(0, m.videoWidth-4), "…") + " "
+	}
+	top += strings.Repeat(b.Top, max(0, m.videoWidth-lipgloss.Width(top)))
+
+	lines := strings.Split(video, "\n")
+	rows := make([]string, 0, len(lines)+2)
+	rows = append(rows, edge.Render(b.TopLeft+top+b.TopRight))
+	for _, line := range lines {
+		pad := strings.Repeat(" ", max(0, m.videoWidth-lipgloss.Width(line)))
+		rows = append(rows, edge.Render(b.Left)+line+pad+edge.Render(b.Right))
+	}
+	rows = append(rows, edge.Render(b.BottomLeft+strings.Repeat(b.Bottom, m.videoWidth)+b.BottomRight))
+	return strings.Join(rows, "\n")
+}
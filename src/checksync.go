@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// syncTolerance is how far the audio or subtitles may run from the video's
+// length before check-sync reports a mismatch. MP3 padding alone accounts
+// for a few frames.
+const syncTolerance = time.Second
+
+// checkSync compares the video's length with the audio's and with when the
+// subtitles end, printing what it found with flags that may fix each
+// mismatch. It reports false if any is past syncTolerance.
+func checkSync(frameCount int) (bool, error) {
+	video := frameTime(frameCount)
+	fmt.Printf("Video:     %s, %d frames\n", formatTimestamp(video), frameCount)
+	ok := true
+
+	if audioFile == "" {
+		fmt.Println("Audio:     none")
+	} else if audio, err := audioDuration(audioFile); os.IsNotExist(err) {
+		fmt.Printf("Audio:     %s not found\n", audioFile)
+	} else if err != nil {
+		return false, err
+	} else {
+		diff := audio - video
+		fmt.Printf("Audio:     %s, %s\n", formatTimestamp(audio), describeDrift(diff, "the video"))
+		if diff.Abs() > syncTolerance {
+			ok = false
+			if frameTimestamps == nil {
+				// The most common cause is frames extracted at another rate
+				fps := float64(frameCount) / audio.Seconds()
+				fmt.Printf("  If the frames were extracted at another rate, try -source-fps %.0f\n", fps)
+			}
+			if diff > 0 {
+				fmt.Printf("  If the audio has %s extra at the start, try -audio-delay %d\n", diff.Round(time.Millisecond), diff.Milliseconds())
+			}
+		}
+	}
+
+	for _, lang := range []string{"ja", "en"} {
+		subs, err := loadSubtitles(lang)
+		if err != nil {
+			return false, err
+		}
+		if len(subs) == 0 {
+			fmt.Printf("Subs (%s): none\n", lang)
+			continue
+		}
+		first, last := subs[0].StartTime, subs[0].EndTime
+		for _, sub := range subs {
+			first = min(first, sub.StartTime)
+			last = max(last, sub.EndTime)
+		}
+		fmt.Printf("Subs (%s): %s to %s\n", lang, formatTimestamp(first), formatTimestamp(last))
+		// Cues ending early are normal, credits and outros go uncaptioned
+		if over := last - video; over > syncTolerance {
+			ok = false
+			fmt.Printf("  Cues run %s past the end of the video, if they are late try -sub-offset %s\n",
+				over.Round(time.Millisecond), (-over).Round(time.Millisecond))
+		}
+	}
+
+	if ok {
+		fmt.Println("In sync")
+	}
+	return ok, nil
+}
+
+// describeDrift says how a length compares with another, of what
+func describeDrift(diff time.Duration, what string) string {
+	switch {
+	case diff.Abs() <= syncTolerance:
+		return "matching " + what
+	case diff > 0:
+		return fmt.Sprintf("%s longer than %s", diff.Round(time.Millisecond), what)
+	}
+	return fmt.Sprintf("%s shorter than %s", (-diff).Round(time.Millisecond), what)
+}
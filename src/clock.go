@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// frameClock sends the player's ticks from one goroutine with one reusable
+// timer, instead of a sleeping command per frame. Ticks are scheduled at
+// absolute times, so the time a tick arrives late is taken off the next
+// frame rather than adding up.
+type frameClock struct {
+	send     func(tea.Msg)
+	schedule chan time.Time
+}
+
+// localClock ticks the player in this terminal. SSH sessions have no clock
+// and tick with commands.
+var localClock *frameClock
+
+// newFrameClock creates a clock, which ticks once started
+func newFrameClock() *frameClock {
+	return &frameClock{schedule: make(chan time.Time, 1)}
+}
+
+// start runs the clock until ctx is done, delivering ticks with send. Call
+// it before the program runs.
+func (c *frameClock) start(ctx context.Context, send func(tea.Msg)) {
+	c.send = send
+	go c.run(ctx)
+}
+
+// started reports whether ticks can be scheduled on the clock
+func (c *frameClock) started() bool {
+	return c != nil && c.send != nil
+}
+
+// at schedules the next tick at t, replacing one that hasn't fired yet
+func (c *frameClock) at(t time.Time) {
+	select {
+	case <-c.schedule:
+	default:
+	}
+	c.schedule <- t
+}
+
+// run fires a tick at each scheduled time
+func (c *frameClock) run(ctx context.Context) {
+	defer recoverPanic()
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-c.schedule:
+			timer.Reset(time.Until(t))
+		case now := <-timer.C:
+			c.send(tickMsg(now))
+		}
+	}
+}
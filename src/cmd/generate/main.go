@@ -0,0 +1,14 @@
+// Command generate extracts the frames the player reads from a video with
+// ffmpeg, as grayscale PNGs numbered from out0001.png. The player runs the
+// same code as its generate subcommand.
+package main
+
+import (
+	"os"
+
+	"senshukai/generate"
+)
+
+func main() {
+	generate.Main(os.Args[0], os.Args[1:])
+}
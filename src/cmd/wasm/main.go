@@ -0,0 +1,44 @@
+//go:build js && wasm
+
+// Command wasm exposes the ASCII renderer to JavaScript as ImageToASCII, so
+// a web page can convert images in the browser.
+package main
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"syscall/js"
+
+	"senshukai/render"
+)
+
+// imageToASCII implements ImageToASCII(data, cols, rows). data is a
+// Uint8Array holding a PNG, JPEG or GIF file. It returns the rendered text,
+// or an Error if the size is under one cell or the image can't be decoded.
+func imageToASCII(this js.Value, args []js.Value) any {
+	if len(args) != 3 {
+		return js.Global().Get("Error").New("ImageToASCII(data, cols, rows) takes 3 arguments")
+	}
+	cols, rows := args[1].Int(), args[2].Int()
+	if cols < 1 || rows < 1 {
+		return js.Global().Get("Error").New("ImageToASCII cols and rows must be at least 1")
+	}
+
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	return render.BlocksString(img, cols, rows, false)
+}
+
+func main() {
+	js.Global().Set("ImageToASCII", js.FuncOf(imageToASCII))
+	// Keep the Go runtime alive for calls from JavaScript
+	select {}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"senshukai/generate"
+)
+
+// subcommands lists the player's commands for -help. Each is a shorthand
+// for the flags that select its mode, so flags work the same either way.
+var subcommands = []struct {
+	name, args, desc string
+}{
+	{"play", "[flags]", "play the video, the default without a command"},
+	{"generate", "[generate flags]", "extract frames from a video with ffmpeg, like cmd/generate"},
+	{"doctor", "[flags]", "check frames, audio, subtitles and the terminal, then exit"},
+	{"check-sync", "[flags]", "compare the video, audio and subtitle lengths, like -check-sync"},
+	{"image", "FILE -at MM:SS [flags]", "write the frame at a time as a PNG, like -shot"},
+	{"export", "DIR [flags]", "render every frame as text for -prerendered, like -headless-render"},
+	{"diff", "DIR_A DIR_B [flags]", "compare two frame sets frame by frame, like -diff"},
+}
+
+// routeSubcommand turns a command at the start of args into the flags for
+// its mode, returning the flags to parse and whether doctor was asked for.
+// generate shares no flags with the player, so it runs here and exits.
+func routeSubcommand(args []string) ([]string, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		// Flags alone play, as before there were commands
+		return args, false
+	}
+	name := filepath.Base(os.Args[0])
+	command, rest := args[0], args[1:]
+	switch command {
+	case "play":
+		return rest, false
+	case "doctor":
+		return rest, true
+	case "check-sync":
+		return append([]string{"-check-sync"}, rest...), false
+	case "generate":
+		generate.Main(name+" generate", rest)
+		os.Exit(0)
+	case "image", "export":
+		flagName, example := "-shot", "shot.png -at 1:00"
+		if command == "export" {
+			flagName, example = "-headless-render", "prerendered"
+		}
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			fmt.Printf("Error: %s needs a path to write to, like %s %s %s\n", command, name, command, example)
+			os.Exit(1)
+		}
+		return append([]string{flagName, rest[0]}, rest[1:]...), false
+	case "diff":
+		if len(rest) < 2 || strings.HasPrefix(rest[0], "-") || strings.HasPrefix(rest[1], "-") {
+			fmt.Printf("Error: diff needs two frame directories, like %s diff frames frames-resized\n", name)
+			os.Exit(1)
+		}
+		return append([]string{"-diff", rest[0], "-diff-to", rest[1]}, rest[2:]...), false
+	}
+	fmt.Printf("Error: unknown command %q, run %s -h for the commands\n", command, name)
+	os.Exit(1)
+	return nil, false
+}
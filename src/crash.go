@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crashProgram is the player running in this terminal, so a panic outside
+// Bubble Tea can give the terminal back. Nil in SSH mode, where sessions have
+// their own terminals.
+var crashProgram *tea.Program
+
+// openAudio holds the audio players that haven't been closed
+var openAudio sync.Map // *AudioPlayer -> struct{}
+
+// recoverPanic restores the terminal, stops audio and exits with the panic
+// and its stack printed. Defer it first thing in main and in goroutines the
+// player starts itself; Bubble Tea already recovers panics in Update, View
+// and commands.
+func recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	if crashProgram != nil {
+		// Leaves the alt screen, shows the cursor and undoes raw mode
+		crashProgram.ReleaseTerminal()
+	}
+	closeAudio()
+	fmt.Fprintf(os.Stderr, "\x1b[0mpanic: %v\n\n%s", r, stack)
+	os.Exit(2)
+}
+
+// closeAudio closes every audio player still open. A player whose lock is
+// held by the panicking goroutine would block, so it's given a moment and
+// then left to die with the process.
+func closeAudio() {
+	done := make(chan struct{})
+	go func() {
+		openAudio.Range(func(key, _ any) bool {
+			key.(*AudioPlayer).Close()
+			return true
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+}
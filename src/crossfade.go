@@ -0,0 +1,47 @@
+package main
+
+import (
+	"image"
+
+	"senshukai/render"
+)
+
+// fadeFrames returns how many frames at the end of the current video are
+// blended into the start of the next playlist entry, 0 when not crossfading.
+// At most half the video fades so short entries still show on their own.
+func (m *Model) fadeFrames() int {
+	if m.crossfade <= 0 || !m.hasNextEntry() {
+		return 0
+	}
+	end := m.clipStart + m.clipFrames
+	n := end - frameAt(frameTime(end)-m.crossfade)
+	return max(0, min(n, m.clipFrames/2))
+}
+
+// fadeSources returns the files of the last n frames of the current video.
+// Only the names are kept, the next entry's loader decodes them as it
+// renders the frames they fade into.
+func (m *Model) fadeSources(n int) []string {
+	files := make([]string, n)
+	for i := range files {
+		files[i] = getFrameFilename(m.clipFrames - n + i + 1)
+	}
+	return files
+}
+
+// fadeIn returns the frameNum-th frame of this video, img, with the previous
+// video's frame blended in. img takes over more of the picture as the fade
+// goes on. It's copied first since it may be shared with the source cache.
+func fadeIn(img *image.Gray, frameNum int, opts renderOptions) (*image.Gray, error) {
+	prev, err := loadGrayFrame(opts.fadeFrom[frameNum-1], opts.bg)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.crop.Empty() && opts.crop.In(prev.Bounds()) {
+		prev = cropGray(prev, opts.crop)
+	}
+	blended := image.NewGray(img.Bounds())
+	copy(blended.Pix, img.Pix)
+	render.Blend(blended, prev, 1-float64(frameNum)/float64(len(opts.fadeFrom)+1))
+	return blended, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// crtEffect dims every other row of a text frame like CRT scanlines, and
+// can tint it phosphor green. The zero value is off.
+type crtEffect struct {
+	intensity float64 // how much the scanlines are dimmed, 0 to 1
+	green     bool
+}
+
+// ansi256Colors matches the color escapes of half-block and subpixel frames
+var ansi256Colors = regexp.MustCompile(`\x1b\[38;5;(\d+);48;5;(\d+)m`)
+
+// on reports whether the effect changes frames
+func (c crtEffect) on() bool {
+	return c.intensity > 0 || c.green
+}
+
+// apply post-processes a rendered text frame. Frames drawn in 256-color
+// grays have their colors dimmed or tinted; plain ASCII rows are colored
+// whole. light dims ASCII rows toward white instead of black.
+func (c crtEffect) apply(frame string, light bool) string {
+	if !c.on() {
+		return frame
+	}
+	lines := strings.Split(frame, "\n")
+	for y, line := range lines {
+		dim := 1.0
+		if y%2 == 1 {
+			dim = 1 - c.intensity
+		}
+		switch {
+		case strings.TrimSpace(line) == "":
+			// Letterbox rows have nothing to dim
+		case strings.Contains(line, "\x1b[38;5;"):
+			lines[y] = ansi256Colors.ReplaceAllStringFunc(line, func(esc string) string {
+				m := ansi256Colors.FindStringSubmatch(esc)
+				fg, _ := strconv.Atoi(m[1])
+				bg, _ := strconv.Atoi(m[2])
+				return fmt.Sprintf("\x1b[38;5;%d;48;5;%dm", c.color(ansi256Gray(fg), dim), c.color(ansi256Gray(bg), dim))
+			})
+		case dim < 1 || c.green:
+			v := 255 * dim
+			if light {
+				v = 255 * (1 - dim)
+			}
+			lines[y] = fmt.Sprintf("\x1b[38;5;%dm%s\x1b[0m", c.color(uint8(v), 1), line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// color returns the 256-color palette index for a gray dimmed by dim, in
+// green if tinted
+func (c crtEffect) color(v uint8, dim float64) int {
+	v = uint8(float64(v) * dim)
+	if c.green {
+		// The color cube's pure greens, 0 to 5
+		return 16 + 6*int((float64(v)*5+127)/255)
+	}
+	return grayToANSI256(v)
+}
+
+// ansi256Gray is the gray level of a palette color written by grayToANSI256
+func ansi256Gray(index int) uint8 {
+	switch {
+	case index >= 232:
+		return uint8(8 + 10*(index-232))
+	case index == 231:
+		return 255
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// frameTiming is how long rendering a frame took
+type frameTiming struct {
+	decode  time.Duration // reading and converting the image to grayscale
+	convert time.Duration // cropping, scaling and encoding for the terminal
+}
+
+// debugOverlay renders render timing and loading stats in a small box
+func (m Model) debugOverlay() string {
+	total := "?"
+	if m.totalFrames > 0 {
+		total = fmt.Sprint(m.totalFrames)
+	}
+	lines := []string{
+		fmt.Sprintf("decode   %v", m.lastTiming.decode.Round(time.Microsecond)),
+		fmt.Sprintf("convert  %v", m.lastTiming.convert.Round(time.Microsecond)),
+		fmt.Sprintf("tick     %v / %v", m.tickInterval.Round(time.Millisecond),
+			time.Duration(float64(frameLength(m.clipStart+m.currentFrame))/m.speed()).Round(time.Millisecond)),
+		fmt.Sprintf("routines %d", runtime.NumGoroutine()),
+		fmt.Sprintf("loaded   %d/%s", m.loaded, total),
+		fmt.Sprintf("memory   %dMB", frameMemory(m.frames)>>20),
+	}
+	return m.theme.Status.
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+}
+
+// overlayTopRight draws overlay over the top right corner of view, keeping
+// the rest of each line as is
+func overlayTopRight(view, overlay string, width int) string {
+	lines := strings.Split(view, "\n")
+	for i, row := range strings.Split(overlay, "\n") {
+		if i >= len(lines) {
+			lines = append(lines, "")
+		}
+		left := max(0, width-lipgloss.Width(row))
+		line := ansi.Truncate(lines[i], left, "")
+		// Reset any style cut off mid-line before drawing the overlay
+		lines[i] = line + "\x1b[0m" + strings.Repeat(" ", left-lipgloss.Width(line)) + row
+	}
+	return strings.Join(lines, "\n")
+}
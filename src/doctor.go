@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// doctor checks what playback needs, frames, audio, subtitles and the
+// terminal, and prints a line for each. It returns false if anything would
+// stop playback.
+func doctor() bool {
+	ok := true
+	report := func(status, what, detail string) {
+		if status == "FAIL" {
+			ok = false
+		}
+		fmt.Printf("[%-4s] %-10s %s\n", status, what, detail)
+	}
+
+	source := frameDir + "/"
+	if framePack != nil {
+		source = "pack"
+	}
+	count, err := openFrames()
+	switch {
+	case err != nil:
+		report("FAIL", "frames", fmt.Sprintf("%v, run the generate subcommand", err))
+	case count == 0:
+		report("FAIL", "frames", fmt.Sprintf("no frames in %s, run the generate subcommand", source))
+	default:
+		if first, err := loadFrame(1, nil); err != nil {
+			report("FAIL", "frames", fmt.Sprintf("%d frames in %s, but the first can't be read: %v", count, source, err))
+		} else {
+			b := first.Bounds()
+			report("ok", "frames", fmt.Sprintf("%d frames of %dx%d in %s", count, b.Dx(), b.Dy(), source))
+		}
+	}
+
+	switch {
+	case noAudioMode:
+		report("ok", "audio", "disabled with -no-audio")
+	case audioFile == "":
+		report("ok", "audio", "none for these frames")
+	default:
+		if player, err := NewAudioPlayer(); err != nil {
+			// Playback carries on without audio
+			report("warn", "audio", err.Error())
+		} else {
+			player.Close()
+			report("ok", "audio", fmt.Sprintf("%s at %d Hz", audioFile, player.sampleRate))
+		}
+	}
+
+	for _, lang := range []string{"ja", "en"} {
+		if subs, err := loadSubtitles(lang); err != nil {
+			report("FAIL", "subtitles", fmt.Sprintf("%s: %v", lang, err))
+		} else {
+			report("ok", "subtitles", fmt.Sprintf("%s: %d cues", lang, len(subs)))
+		}
+	}
+
+	term := os.Getenv("TERM")
+	if term == "" {
+		report("warn", "terminal", "TERM is not set")
+	} else {
+		report("ok", "terminal", fmt.Sprintf("TERM=%s COLORTERM=%s", term, os.Getenv("COLORTERM")))
+	}
+	if graphicsMode != "" {
+		if detectGraphics(graphicsMode, term, os.Environ()) == "" {
+			report("warn", "graphics", graphicsMode+" doesn't look supported, playback falls back to ASCII")
+		} else {
+			report("ok", "graphics", graphicsMode+" looks supported")
+		}
+	}
+
+	if path, err := exec.LookPath("ffmpeg"); err != nil {
+		report("warn", "ffmpeg", "not found in PATH, needed only to generate frames")
+	} else {
+		report("ok", "ffmpeg", path)
+	}
+	return ok
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// downloadAttempts is how many times a frame download is tried before
+// giving up. Each retry resumes where the last one stopped.
+const downloadAttempts = 4
+
+// downloadProgressMsg reports download progress to the loading screen
+type downloadProgressMsg struct {
+	done, total int64  // bytes, total is -1 if the server didn't say
+	status      string // what is happening, like a retry
+}
+
+// downloadDoneMsg ends the loading screen, with an error if the frames
+// couldn't be fetched
+type downloadDoneMsg struct {
+	err error
+}
+
+// downloadModel is the loading screen shown while frames are fetched
+type downloadModel struct {
+	url      string
+	progress downloadProgressMsg
+	width    int
+	cancel   context.CancelFunc
+	done     downloadDoneMsg
+	theme    Theme
+}
+
+// Init initializes the loading screen
+func (m downloadModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update records progress and quits once the download is done
+func (m downloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" || msg.String() == "q" {
+			m.cancel()
+		}
+	case downloadProgressMsg:
+		if msg.done < 0 {
+			// A status update, the byte counts stand
+			m.progress.status = msg.status
+		} else {
+			m.progress = msg
+		}
+	case downloadDoneMsg:
+		m.done = msg
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// View shows a progress bar of the download
+func (m downloadModel) View() string {
+	p := m.progress
+	size := fmt.Sprintf("%.1f MB", float64(p.done)/(1<<20))
+	ratio := 0.0
+	if p.total > 0 {
+		size = fmt.Sprintf("%.1f/%.1f MB", float64(p.done)/(1<<20), float64(p.total)/(1<<20))
+		ratio = min(1, float64(p.done)/float64(p.total))
+	}
+	width := max(10, min(m.width, 80)-lipgloss.Width(size)-1)
+	filled := int(ratio * float64(width))
+	bar := m.theme.Highlight.Render(strings.Repeat("━", filled)) +
+		m.theme.Controls.Render(strings.Repeat("─", width-filled))
+
+	lines := []string{"Downloading frames from " + m.url, "", bar + " " + size}
+	if p.status != "" {
+		lines = append(lines, m.theme.Status.Render(p.status))
+	}
+	lines = append(lines, "", m.theme.Controls.Render("[q] cancel"))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// framesCacheDir returns where frames from url are kept, by checksum if
+// there is one so a changed url with the same frames isn't fetched again
+func framesCacheDir(url, sum string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := sum
+	if key == "" {
+		hash := sha256.Sum256([]byte(url))
+		key = hex.EncodeToString(hash[:8])
+	}
+	return filepath.Join(cache, "senshukai", key), nil
+}
+
+// fetchFrames returns a frames directory with the archive at url unpacked,
+// downloading it with a loading screen on first use. If sum is set, the
+// archive must have that SHA-256.
+func fetchFrames(url, sum string) (string, error) {
+	dir, err := framesCacheDir(url, sum)
+	if err != nil {
+		return "", err
+	}
+	frames := filepath.Join(dir, "frames")
+	if _, err := os.Stat(frames); err == nil {
+		return framesRoot(frames)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := tea.NewProgram(downloadModel{
+		url:    url,
+		cancel: cancel,
+		theme:  newTheme(themeName, lipgloss.DefaultRenderer()),
+	})
+	go func() {
+		defer recoverPanic()
+		err := downloadFrames(ctx, url, sum, dir, func(msg downloadProgressMsg) { p.Send(msg) })
+		p.Send(downloadDoneMsg{err: err})
+	}()
+	final, err := p.Run()
+	if err != nil {
+		return "", err
+	}
+	if err := final.(downloadModel).done.err; err != nil {
+		return "", err
+	}
+	return framesRoot(frames)
+}
+
+// downloadFrames downloads the archive at url into dir, checks it and
+// unpacks it to dir/frames
+func downloadFrames(ctx context.Context, url, sum, dir string, report func(downloadProgressMsg)) error {
+	archive := filepath.Join(dir, "frames.download")
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if err = downloadAttempt(ctx, url, archive, report); err == nil || ctx.Err() != nil || attempt == downloadAttempts {
+			break
+		}
+		// Both numbers count retries: attempt n failing is followed by retry n
+		report(downloadProgressMsg{done: -1, status: fmt.Sprintf("retrying (%d/%d): %v", attempt, downloadAttempts-1, err)})
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("download cancelled")
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading frames: %w", err)
+	}
+
+	if sum != "" {
+		report(downloadProgressMsg{done: -1, status: "verifying"})
+		if err := checkSHA256(archive, sum); err != nil {
+			// A bad download isn't worth resuming
+			os.Remove(archive)
+			return err
+		}
+	}
+	report(downloadProgressMsg{done: -1, status: "extracting"})
+	// Unpack beside the final directory so a partial unpack is never used
+	tmp := filepath.Join(dir, "frames.tmp")
+	os.RemoveAll(tmp)
+	if err := extractArchive(archive, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("error extracting frames: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "frames")); err != nil {
+		return err
+	}
+	os.Remove(archive)
+	return nil
+}
+
+// downloadAttempt downloads url to path, resuming after the bytes already
+// there if the server supports ranges
+func downloadAttempt(ctx context.Context, url, path string, report func(downloadProgressMsg)) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server sent the whole file, start over
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		if offset, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Everything was downloaded last time
+		return nil
+	default:
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	done := offset
+	buf := make([]byte, 64<<10)
+	last := time.Time{}
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				return err
+			}
+			done += int64(n)
+			// Redrawing on every read would flood the screen
+			if time.Since(last) > 100*time.Millisecond {
+				report(downloadProgressMsg{done: done, total: total})
+				last = time.Now()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	report(downloadProgressMsg{done: done, total: total})
+	if total >= 0 && done != total {
+		return fmt.Errorf("download ended at %d of %d bytes", done, total)
+	}
+	return nil
+}
+
+// validSHA256 reports whether s is a SHA-256 sum in hex
+func validSHA256(s string) bool {
+	sum, err := hex.DecodeString(s)
+	return err == nil && len(sum) == sha256.Size
+}
+
+// checkSHA256 checks that the file at path has the hex SHA-256 sum
+func checkSHA256(path, sum string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, sum) {
+		return fmt.Errorf("frames checksum mismatch: got %s, want %s", got, sum)
+	}
+	return nil
+}
+
+// extractArchive unpacks a zip, tar or gzipped tar archive into dir, telling
+// them apart by their first bytes
+func extractArchive(path, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return fmt.Errorf("not an archive: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		return extractZip(file, info.Size(), dir)
+	case bytes.Equal(magic[:2], []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, dir)
+	}
+	return extractTar(file, dir)
+}
+
+// extractZip unpacks a zip archive into dir
+func extractZip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		// Skip the resource forks macOS adds to zips
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(src, dir, f.Name)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTar unpacks the regular files of a tar archive into dir
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extractFile(tr, dir, hdr.Name); err != nil {
+			return err
+		}
+	}
+}
+
+// extractFile writes an archive member to its path under dir, refusing
+// names that would land outside it
+func extractFile(r io.Reader, dir, name string) error {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// framesRoot returns the directory holding the frames of an unpacked
+// archive, which is often a single folder like frames/ inside it
+func framesRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	if len(entries) == 0 {
+		return "", errors.New("frames archive is empty")
+	}
+	return dir, nil
+}
@@ -1,23 +1,139 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"senshukai/badz"
 )
 
-// countFrames counts the number of frame files in the frames directory
+// framePattern describes how frame files are named: a prefix, the frame
+// number zero padded to digits (0 for no padding), then a suffix
+type framePattern struct {
+	prefix string
+	suffix string
+	digits int
+	start  int // number of the first frame file
+}
+
+// frameDir is the directory frames are played from, changed by -playlist
+var frameDir = "frames"
+
+// frameNaming is how the frames being played are named, detected from the
+// frames directory or set with flags
+var frameNaming = framePattern{prefix: "out", suffix: ".png", digits: 4, start: 1}
+
+// frameNamingSet is set when frameNaming came from flags and shouldn't be
+// detected from the frame files
+var frameNamingSet bool
+
+// frameManifestFile, in the frames directory, lists the file to load for
+// each frame, in order, for frame sets deduplicated with cmd/generate -dedup
+const frameManifestFile = "manifest.txt"
+
+// frameManifest holds the file name of every frame when frames/ has a
+// manifest, or nil when frames are named by number
+var frameManifest []string
+
+// loadFrameManifest reads a manifest of frame file names, one per line
+func loadFrameManifest(path string) ([]string, error) {
+	file, err := openFrameFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading frame manifest: %w", err)
+	}
+	return names, nil
+}
+
+// framePack holds every frame when playing a pack with -pack, or nil to
+// load frames from frames/
+var framePack *badz.Reader
+
+// loadFrame decodes the frameNum-th frame of the clip, from the pack if
+// there is one or else from its PNG file
+func loadFrame(frameNum int, bg color.Color) (*image.Gray, error) {
+	if framePack != nil {
+		return framePack.Frame(clipFrom - 1 + frameNum - 1)
+	}
+	return loadGrayFrame(getFrameFilename(frameNum), bg)
+}
+
+// openFrames reads the timestamps, manifest and naming of the frames in
+// frameDir and counts them. Naming is detected from the files unless it was
+// set with flags.
+func openFrames() (int, error) {
+	var err error
+	// Variable frame rate sources time every frame
+	frameTimestamps, err = loadFrameTimestamps(filepath.Join(frameDir, frameTimestampsFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	// Deduplicated frame sets name every frame in a manifest
+	frameManifest, err = loadFrameManifest(filepath.Join(frameDir, frameManifestFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	if !frameNamingSet {
+		if pattern, err := detectFramePattern(frameDir); err == nil {
+			frameNaming = pattern
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Warn("could not detect frame file names, set -frame-pattern", "error", err)
+		}
+	}
+
+	count, err := countFrames()
+	if err != nil {
+		return 0, err
+	}
+	if frameTimestamps != nil && len(frameTimestamps) != count {
+		return 0, fmt.Errorf("%s has %d timestamps for %d frames",
+			filepath.Join(frameDir, frameTimestampsFile), len(frameTimestamps), count)
+	}
+	return count, nil
+}
+
+// countFrames counts the number of frame files in the frames directory. Frame
+// files whose number isn't padded to the pattern's digits are reported as an
+// error, since they would never be loaded.
 func countFrames() (int, error) {
-	entries, err := os.ReadDir("frames")
+	if framePack != nil {
+		return framePack.Len(), nil
+	}
+	if frameManifest != nil {
+		return len(frameManifest), nil
+	}
+	entries, err := readFrameDir(frameDir)
 	if err != nil {
 		return 0, fmt.Errorf("error reading frames directory: %w", err)
 	}
 
 	count := 0
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".png") {
-			if strings.HasPrefix(entry.Name(), "out") {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), frameNaming.suffix) {
+			if strings.HasPrefix(entry.Name(), frameNaming.prefix) {
+				if _, ok := extractFrameNumber(entry.Name()); !ok {
+					return 0, fmt.Errorf("frame %s doesn't match -frame-digits %d", entry.Name(), frameNaming.digits)
+				}
 				count++
 			}
 		}
@@ -26,19 +142,188 @@ func countFrames() (int, error) {
 	return count, nil
 }
 
-// getFrameFilename returns the filename for a given frame number
+// clipFrom and clipTo limit playback to a range of frames, numbered from 1
+// and inclusive. clipTo is 0 to play to the last frame.
+var clipFrom, clipTo = 1, 0
+
+// arg to hold a single frame without playing, or 0 to play
+var repeatFrame int
+
+// countClipFrames returns how many frames the clip plays
+func countClipFrames() (int, error) {
+	total, err := countFrames()
+	if err != nil {
+		return 0, err
+	}
+	if clipTo > 0 {
+		total = min(total, clipTo)
+	}
+	return max(0, total-clipFrom+1), nil
+}
+
+// getFrameFilename returns the filename of the frameNum-th frame of the
+// clip, counting from 1 whatever number the first file has
 func getFrameFilename(frameNum int) string {
-	return fmt.Sprintf("frames/out%04d.png", frameNum)
+	if i := clipFrom - 1 + frameNum - 1; i < len(frameManifest) {
+		return filepath.Join(frameDir, frameManifest[i])
+	}
+	return filepath.Join(frameDir, fmt.Sprintf("%s%0*d%s", frameNaming.prefix, frameNaming.digits,
+		frameNaming.start+clipFrom-1+frameNum-1, frameNaming.suffix))
+}
+
+// extractFrameNumber extracts the frame number from a filename like
+// "out0001.png", reporting false if it isn't padded to the pattern's digits
+func extractFrameNumber(filename string) (int, bool) {
+	// Remove the prefix and suffix around the number
+	numberStr := strings.TrimPrefix(filename, frameNaming.prefix)
+	numberStr = strings.TrimSuffix(numberStr, frameNaming.suffix)
+	if frameNaming.digits > 0 && len(numberStr) != frameNaming.digits {
+		return 0, false
+	}
+
+	num, err := strconv.Atoi(numberStr)
+	return num, err == nil
 }
 
-// extractFrameNumber extracts the frame number from a filename like "out0001.png"
-func extractFrameNumber(filename string) int {
-	// Remove "out" prefix and ".png" suffix
-	numberStr := strings.TrimPrefix(filename, "out")
-	numberStr = strings.TrimSuffix(numberStr, ".png")
+// parseFramePattern parses a printf-style pattern like "out%04d.png"
+func parseFramePattern(s string) (framePattern, error) {
+	prefix, rest, ok := strings.Cut(s, "%")
+	if !ok {
+		return framePattern{}, fmt.Errorf("invalid frame pattern %q (want something like out%%04d.png)", s)
+	}
+	width, suffix, ok := strings.Cut(rest, "d")
+	digits, err := strconv.Atoi("0" + width)
+	if !ok || err != nil || strings.Contains(suffix, "%") {
+		return framePattern{}, fmt.Errorf("invalid frame pattern %q (want something like out%%04d.png)", s)
+	}
+	return framePattern{prefix: prefix, suffix: suffix, digits: digits, start: 1}, nil
+}
+
+// detectFramePattern infers how the PNG files in dir are named from the last
+// run of digits in each name. It fails if the names don't share one pattern.
+func detectFramePattern(dir string) (framePattern, error) {
+	entries, err := readFrameDir(dir)
+	if err != nil {
+		return framePattern{}, fmt.Errorf("error reading frames directory: %w", err)
+	}
+
+	var pattern framePattern
+	var first string
+	sameWidth, padded := true, false
+	width := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".png") {
+			continue
+		}
+
+		// Split around the last run of digits
+		end := strings.LastIndexAny(name, "0123456789") + 1
+		if end == 0 {
+			return framePattern{}, fmt.Errorf("frame %s has no number", name)
+		}
+		start := strings.LastIndexFunc(name[:end], func(r rune) bool { return r < '0' || r > '9' }) + 1
+		prefix, numberStr, suffix := name[:start], name[start:end], name[end:]
+		num, err := strconv.Atoi(numberStr)
+		if err != nil {
+			return framePattern{}, fmt.Errorf("frame %s has an invalid number", name)
+		}
+
+		if first == "" {
+			pattern = framePattern{prefix: prefix, suffix: suffix, start: num}
+			width = len(numberStr)
+			first = name
+		} else if prefix != pattern.prefix || suffix != pattern.suffix {
+			return framePattern{}, fmt.Errorf("frames %s and %s don't share a name pattern", first, name)
+		}
+		pattern.start = min(pattern.start, num)
+		sameWidth = sameWidth && len(numberStr) == width
+		padded = padded || (len(numberStr) > 1 && numberStr[0] == '0')
+	}
+
+	switch {
+	case first == "":
+		return framePattern{}, fmt.Errorf("no PNG frames in %s", dir)
+	case sameWidth:
+		pattern.digits = width
+	case padded:
+		return framePattern{}, fmt.Errorf("frame numbers in %s are padded to different widths", dir)
+	}
+	return pattern, nil
+}
+
+// parseColor parses a #rgb or #rrggbb hex color, or black or white
+func parseColor(s string) (color.Color, error) {
+	switch strings.ToLower(s) {
+	case "black":
+		return color.Black, nil
+	case "white":
+		return color.White, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q (want #rgb, #rrggbb, black or white)", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// compositeOver blends an image with transparency over a solid background
+func compositeOver(img image.Image, bg color.Color) image.Image {
+	// Fully opaque formats have nothing to composite
+	if _, ok := img.(*image.Gray); ok {
+		return img
+	}
+
+	br, bgG, bb, _ := bg.RGBA()
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Colors are alpha-premultiplied, so add the uncovered background
+			r, g, b, a := img.At(x, y).RGBA()
+			inv := 0xffff - a
+			out.Set(x, y, color.RGBA64{
+				R: uint16(r + br*inv/0xffff),
+				G: uint16(g + bgG*inv/0xffff),
+				B: uint16(b + bb*inv/0xffff),
+				A: 0xffff,
+			})
+		}
+	}
+	return out
+}
+
+// parseCrop parses a crop region given as "x,y,w,h" in source pixels
+func parseCrop(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop %q (want x,y,w,h)", s)
+	}
+	var n [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v < 0 {
+			return image.Rectangle{}, fmt.Errorf("invalid crop %q (want x,y,w,h)", s)
+		}
+		n[i] = v
+	}
+	if n[2] == 0 || n[3] == 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop %q (width and height must be positive)", s)
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
 
-	if num, err := strconv.Atoi(numberStr); err == nil {
-		return num
+// cropGray copies the crop region of img into a new image starting at 0,0,
+// since the renderers assume images start at the origin
+func cropGray(img *image.Gray, crop image.Rectangle) *image.Gray {
+	dst := image.NewGray(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	for y := 0; y < crop.Dy(); y++ {
+		copy(dst.Pix[y*dst.Stride:(y+1)*dst.Stride], img.Pix[img.PixOffset(crop.Min.X, crop.Min.Y+y):])
 	}
-	return 0
+	return dst
 }
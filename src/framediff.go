@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+
+	"senshukai/generate"
+)
+
+// args for -diff: the frame sets to compare, the mean difference a frame
+// may have before it's reported, and a file to write every frame's
+// difference to
+var (
+	diffDir, diffToDir string
+	diffThreshold      = 8.0
+	diffCSVPath        string
+)
+
+// Size frames are scaled to before comparing, small enough that resizing
+// noise averages out but large enough to catch changed content
+const diffWidth, diffHeight = 64, 48
+
+// maxDiffListed bounds how many diverging frames are listed one by one
+const maxDiffListed = 20
+
+// loadDiffFrames decodes every frame in dir, scaled to diffWidth x
+// diffHeight. Frames are named as the player would find them in dir.
+func loadDiffFrames(dir string, naming framePattern) ([]*image.Gray, error) {
+	frameDir, frameNaming = dir, naming
+	count, err := openFrames()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no frames found in %s", dir)
+	}
+	clipFrom, clipTo = 1, count
+	frames := make([]*image.Gray, 0, count)
+	for i := 1; i <= count; i++ {
+		img, err := loadFrame(i, nil)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, generate.ScaleGray(img, diffWidth, diffHeight))
+	}
+	return frames, nil
+}
+
+// meanAbsDiff returns the mean absolute difference of two images of the same
+// size, from 0 for identical to 255 for black against white
+func meanAbsDiff(a, b *image.Gray) float64 {
+	sum := 0
+	for i, p := range a.Pix {
+		sum += max(int(p)-int(b.Pix[i]), int(b.Pix[i])-int(p))
+	}
+	return float64(sum) / float64(len(a.Pix))
+}
+
+// diffFrameSets compares the frames in a and b one by one, printing a summary
+// and the frames differing by more than diffThreshold. It reports false if
+// any do or the sets have different frame counts.
+func diffFrameSets(a, b string) (bool, error) {
+	naming := frameNaming
+	framesA, err := loadDiffFrames(a, naming)
+	if err != nil {
+		return false, err
+	}
+	framesB, err := loadDiffFrames(b, naming)
+	if err != nil {
+		return false, err
+	}
+
+	var rows [][]string
+	var diverged []int
+	var total, worst float64
+	worstFrame := 1
+	n := min(len(framesA), len(framesB))
+	for i := range n {
+		d := meanAbsDiff(framesA[i], framesB[i])
+		total += d
+		if d > worst {
+			worst, worstFrame = d, i+1
+		}
+		if d > diffThreshold {
+			diverged = append(diverged, i+1)
+		}
+		rows = append(rows, []string{strconv.Itoa(i + 1), strconv.FormatFloat(d, 'f', 2, 64)})
+	}
+
+	if diffCSVPath != "" {
+		if err := writeDiffCSV(diffCSVPath, rows); err != nil {
+			return false, err
+		}
+	}
+
+	ok := len(diverged) == 0
+	if len(framesA) != len(framesB) {
+		ok = false
+		fmt.Printf("%s has %d frames and %s has %d, comparing the first %d\n", a, len(framesA), b, len(framesB), n)
+	}
+	fmt.Printf("Compared %d frames at %dx%d: mean difference %.2f, worst %.2f at frame %d\n",
+		n, diffWidth, diffHeight, total/float64(n), worst, worstFrame)
+	if len(diverged) == 0 {
+		fmt.Printf("No frames differ by more than %g\n", diffThreshold)
+		return ok, nil
+	}
+	fmt.Printf("%d frames differ by more than %g:\n", len(diverged), diffThreshold)
+	for _, frame := range diverged[:min(len(diverged), maxDiffListed)] {
+		fmt.Printf("  frame %d: %s\n", frame, rows[frame-1][1])
+	}
+	if len(diverged) > maxDiffListed {
+		fmt.Printf("  and %d more\n", len(diverged)-maxDiffListed)
+	}
+	return ok, nil
+}
+
+// writeDiffCSV writes each frame's difference to path, with a header row
+func writeDiffCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"frame", "mean_abs_diff"})
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return file.Close()
+}
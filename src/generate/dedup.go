@@ -0,0 +1,70 @@
+package generate
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestName is the file listing which file to load for each frame of a
+// deduplicated frame set. The player reads it in place of numbered names.
+const manifestName = "manifest.txt"
+
+// dedupFrames removes frames in dir that are byte-for-byte copies of an
+// earlier frame and writes a manifest mapping every frame to the file that
+// holds it. Runs of solid black or white frames collapse to one file each.
+func dedupFrames(dir string) error {
+	manifest := filepath.Join(dir, manifestName)
+	if _, err := os.Stat(manifest); err == nil {
+		return fmt.Errorf("%s is already deduplicated", dir)
+	}
+
+	var names []string
+	var duplicates []string
+	var saved int64
+	seen := make(map[[sha256.Size]byte]string)
+	for frame := 1; ; frame++ {
+		name := fmt.Sprintf(framePattern, frame)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		if original, ok := seen[sum]; ok {
+			names = append(names, original)
+			duplicates = append(duplicates, name)
+			saved += int64(len(data))
+			continue
+		}
+		seen[sum] = name
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no frames named like %s in %s", framePattern, dir)
+	}
+
+	// Write the manifest before removing anything so the set stays playable
+	tmp := manifest + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(names, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	if err := os.Rename(tmp, manifest); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	for _, name := range duplicates {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Deduplicated %d frames into %d files, saving %.1f MB\n",
+		len(names), len(seen), float64(saved)/(1<<20))
+	return nil
+}
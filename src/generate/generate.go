@@ -0,0 +1,359 @@
+// Package generate extracts the frames the player reads from a video with
+// ffmpeg, as grayscale PNGs numbered from out0001.png. It backs both the
+// player's generate subcommand and cmd/generate.
+package generate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// framePattern is how frames are named, matching the player's default
+const framePattern = "out%04d.png"
+
+// pngBytesPerPixel approximates the size of a grayscale PNG frame. Flat
+// animation like Bad Apple compresses far better, so estimates err high.
+const pngBytesPerPixel = 0.25
+
+// args for the source video and output
+var input = "bad_apple.mp4"
+var output = "frames"
+var width = 640
+var fps = 60
+
+// arg to split the video into segments extracted concurrently
+var parallel = 1
+
+// arg to print what would be generated without running ffmpeg
+var dryRun bool
+
+// arg to keep the frames extracted so far when generation fails
+var keepPartial bool
+
+// args to rescale existing frames instead of extracting them, in place or
+// into another directory
+var resize string
+var resizeTo string
+
+// arg to replace duplicate frames with a manifest
+var dedup bool
+
+// arg to encode the frames into a single pack file
+var packPath string
+
+// Main runs frame generation with args, the command line after the program
+// name, which usage messages show as name. It exits on errors.
+func Main(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&input, "i", input, "video to extract frames from")
+	flags.StringVar(&output, "o", output, "directory to write frames to")
+	flags.IntVar(&width, "width", width, "frame width in pixels, height keeps the aspect ratio")
+	flags.IntVar(&fps, "fps", fps, "frames per second to extract")
+	flags.IntVar(&parallel, "parallel", parallel, "split the video into this many segments and extract them concurrently")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the ffmpeg commands and estimated frame count and disk usage without extracting")
+	flags.BoolVar(&keepPartial, "keep-partial", false, "keep the frames extracted so far if generation fails or is interrupted")
+	flags.StringVar(&resize, "resize", "", "rescale the existing frames in -o to WxH, like 320x240, without ffmpeg")
+	flags.StringVar(&resizeTo, "resize-to", "", "write resized frames to this directory instead of replacing them")
+	flags.BoolVar(&dedup, "dedup", false, "remove frames in -o identical to an earlier one, writing a manifest the player reads instead")
+	flags.StringVar(&packPath, "pack", "", "encode the frames in -o into this pack file, like frames.badz, for the player's -pack")
+	flags.Parse(args)
+
+	// Stop ffmpeg and clean up on Ctrl+C instead of leaving partial frames
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Resizing, deduplication and packing work on existing frames, without ffmpeg
+	if resize != "" || dedup || packPath != "" {
+		dst := output
+		var err error
+		if resize != "" {
+			if resizeTo != "" {
+				dst = resizeTo
+			}
+			var w, h int
+			if w, h, err = parseSize(resize); err == nil {
+				err = resizeFrames(ctx, output, dst, w, h)
+			}
+		}
+		if err == nil && dedup {
+			err = dedupFrames(dst)
+		}
+		if err == nil && packPath != "" {
+			err = packFrames(dst, packPath)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if width <= 0 || fps <= 0 || parallel <= 0 {
+		fmt.Println("Error: -width, -fps and -parallel must be positive")
+		os.Exit(1)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		fmt.Println("Error: ffmpeg is required but not found in PATH")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(input); err != nil {
+		fmt.Printf("Error: video file %s not found\n", input)
+		os.Exit(1)
+	}
+	if dryRun {
+		if err := printPlan(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := generate(ctx); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Frame generation complete!")
+}
+
+// generate extracts frames into a temporary directory next to the output
+// and only moves them into place once extraction succeeds, so a failed or
+// interrupted run never leaves a truncated frame sequence behind
+func generate(ctx context.Context) error {
+	output = filepath.Clean(output)
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(output), filepath.Base(output)+"-partial-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary frames directory: %w", err)
+	}
+
+	if parallel == 1 {
+		err = extract(ctx, nil, filepath.Join(tmp, framePattern))
+	} else {
+		err = extractParallel(ctx, tmp, parallel)
+	}
+	if err != nil {
+		if keepPartial {
+			fmt.Printf("Partial frames kept in %s\n", tmp)
+		} else {
+			os.RemoveAll(tmp)
+		}
+		return err
+	}
+	return replaceDir(tmp, output)
+}
+
+// replaceDir renames src to dst, replacing any existing dst. The old dst is
+// moved aside first and restored if the rename fails.
+func replaceDir(src, dst string) error {
+	old := dst + ".old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error moving old frames aside: %w", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(old, dst)
+		return fmt.Errorf("error moving frames into place: %w", err)
+	}
+	return os.RemoveAll(old)
+}
+
+// extract runs ffmpeg on the input, with extra input args like a seek
+// before it, writing frames to pattern
+func extract(ctx context.Context, inputArgs []string, pattern string) error {
+	args := ffmpegArgs(inputArgs, pattern)
+	fmt.Printf("Running ffmpeg command: ffmpeg %s\n", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			// Killed on interrupt, ffmpeg's output isn't interesting
+			return errors.New("interrupted")
+		}
+		return fmt.Errorf("ffmpeg failed: %w\n%s", err, stderr.String())
+	}
+	return nil
+}
+
+// ffmpegArgs returns the ffmpeg arguments to extract frames to pattern
+func ffmpegArgs(inputArgs []string, pattern string) []string {
+	return append(append([]string{}, inputArgs...), "-i", input,
+		"-vf", fmt.Sprintf("scale=%d:-1:flags=lanczos,format=gray,fps=%d", width, fps),
+		pattern, "-y")
+}
+
+// segmentArgs returns the seek arguments for segment i of n, each segment
+// long seconds
+func segmentArgs(i, n int, long float64) []string {
+	args := []string{"-ss", fmt.Sprintf("%.3f", float64(i)*long)}
+	if i < n-1 {
+		// The last segment runs to the end so no frames are lost to rounding
+		args = append(args, "-t", fmt.Sprintf("%.3f", long))
+	}
+	return args
+}
+
+// videoInfo is the input metadata read with ffprobe
+type videoInfo struct {
+	duration float64 // seconds
+	width    int
+	height   int
+}
+
+// probe reads the input's duration and size using ffprobe
+func probe() (videoInfo, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height:format=duration",
+		"-of", "default=noprint_wrappers=1", input).Output()
+	if err != nil {
+		return videoInfo{}, fmt.Errorf("error reading video metadata with ffprobe: %w", err)
+	}
+
+	var info videoInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "duration":
+			info.duration, err = strconv.ParseFloat(value, 64)
+		case "width":
+			info.width, err = strconv.Atoi(value)
+		case "height":
+			info.height, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return videoInfo{}, fmt.Errorf("error reading video %s %q: %w", key, value, err)
+		}
+	}
+	if info.duration <= 0 || info.width <= 0 || info.height <= 0 {
+		return videoInfo{}, fmt.Errorf("ffprobe didn't report the duration and size of %s", input)
+	}
+	return info, nil
+}
+
+// printPlan prints the ffmpeg commands that would run, with the estimated
+// frame count and disk usage
+func printPlan() error {
+	info, err := probe()
+	if err != nil {
+		return err
+	}
+	frames := int(info.duration * float64(fps))
+	// Height after scaling to the frame width, keeping the aspect ratio
+	height := info.height * width / info.width
+	size := float64(frames) * float64(width*height) * pngBytesPerPixel
+
+	fmt.Printf("Output:    %s, extracted to %s-partial-* first\n", output, output)
+	fmt.Printf("Frames:    ~%d (%.1fs at %d fps, %dx%d)\n", frames, info.duration, fps, width, height)
+	fmt.Printf("Disk:      ~%.0f MB\n", size/(1<<20))
+	if parallel == 1 {
+		fmt.Printf("Command:   ffmpeg %s\n", strings.Join(ffmpegArgs(nil, filepath.Join(output+"-partial-*", framePattern)), " "))
+		return nil
+	}
+	long := info.duration / float64(parallel)
+	for i := range parallel {
+		pattern := filepath.Join(output+"-partial-*", "segments-*", strconv.Itoa(i), framePattern)
+		fmt.Printf("Segment %d: ffmpeg %s\n", i+1, strings.Join(ffmpegArgs(segmentArgs(i, parallel, long), pattern), " "))
+	}
+	return nil
+}
+
+// extractParallel splits the video into n time segments and extracts each
+// into its own directory under dir concurrently. The number of frames ffmpeg writes
+// for a segment can be off by one at the edges, so instead of guessing each
+// segment's first frame number, the segments are numbered into dir in order
+// once they're all done.
+func extractParallel(ctx context.Context, dir string, n int) error {
+	info, err := probe()
+	if err != nil {
+		return err
+	}
+	long := info.duration / float64(n)
+
+	tmp, err := os.MkdirTemp(dir, "segments-")
+	if err != nil {
+		return fmt.Errorf("error creating segment directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		segment := filepath.Join(tmp, strconv.Itoa(i))
+		if err := os.Mkdir(segment, 0o755); err != nil {
+			return fmt.Errorf("error creating segment directory: %w", err)
+		}
+		seek := segmentArgs(i, n, long)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = extract(ctx, seek, filepath.Join(segment, framePattern)); errs[i] != nil {
+				// One failed segment fails the whole run
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", i+1, err)
+		}
+	}
+
+	// Number the segments' frames in order into dir
+	next := 1
+	for i := range n {
+		segment := filepath.Join(tmp, strconv.Itoa(i))
+		for frame := 1; ; frame++ {
+			src := filepath.Join(segment, fmt.Sprintf(framePattern, frame))
+			if _, err := os.Stat(src); os.IsNotExist(err) {
+				break
+			}
+			if err := os.Rename(src, filepath.Join(dir, fmt.Sprintf(framePattern, next))); err != nil {
+				return fmt.Errorf("error moving frame: %w", err)
+			}
+			next++
+		}
+	}
+	return checkFrames(dir, next-1)
+}
+
+// checkFrames verifies dir holds frames 1 through count with no gaps and
+// nothing past the end
+func checkFrames(dir string, count int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading frames directory: %w", err)
+	}
+	found := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "out") && strings.HasSuffix(entry.Name(), ".png") {
+			found++
+		}
+	}
+	for frame := 1; frame <= count; frame++ {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf(framePattern, frame))); err != nil {
+			return fmt.Errorf("frame %d is missing", frame)
+		}
+	}
+	if found != count {
+		return fmt.Errorf("found %d frames but extracted %d", found, count)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package generate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"senshukai/badz"
+	"senshukai/render"
+)
+
+// packKeyInterval is how often a pack stores a full frame, one second of
+// video at the default frame rate
+const packKeyInterval = 60
+
+// packFrames encodes the frames in dir into a single pack file at path,
+// following the manifest of a deduplicated set
+func packFrames(dir, path string) error {
+	names, err := frameNames(dir)
+	if err != nil {
+		return err
+	}
+	first, err := decodeFrame(filepath.Join(dir, names[0]))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	b := first.Bounds()
+	pw, err := badz.NewWriter(out, b.Dx(), b.Dy(), len(names), packKeyInterval)
+	if err == nil {
+		err = pw.WriteFrame(first)
+	}
+	for _, name := range names[1:] {
+		if err != nil {
+			break
+		}
+		var img *image.Gray
+		if img, err = decodeFrame(filepath.Join(dir, name)); err == nil {
+			err = pw.WriteFrame(img)
+		}
+	}
+	if err == nil {
+		err = pw.Close()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Packed %d frames into %s (%.1f MB)\n", len(names), path, float64(info.Size())/(1<<20))
+	return nil
+}
+
+// frameNames lists the file of every frame in dir in order, from the
+// manifest if the set was deduplicated
+func frameNames(dir string) ([]string, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, manifestName)); err == nil {
+		return strings.Fields(string(data)), nil
+	}
+
+	var names []string
+	for frame := 1; ; frame++ {
+		name := fmt.Sprintf(framePattern, frame)
+		if _, err := os.Stat(filepath.Join(dir, name)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no frames named like %s in %s", framePattern, dir)
+	}
+	return names, nil
+}
+
+// decodeFrame reads a PNG frame as grayscale
+func decodeFrame(path string) (*image.Gray, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return render.Gray(img), nil
+}
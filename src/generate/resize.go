@@ -0,0 +1,161 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// parseSize parses a size like "320x240"
+func parseSize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(s, "x")
+	w, errW := strconv.Atoi(ws)
+	h, errH := strconv.Atoi(hs)
+	if !ok || errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q (want WxH, like 320x240)", s)
+	}
+	return w, h, nil
+}
+
+// timestampsName is the file the player reads each frame's presentation
+// time from, for variable frame rate sources
+const timestampsName = "timestamps.txt"
+
+// resizeFrames rescales every PNG frame in src to w x h, writing them to
+// dst. Like generate, frames are written to a temporary directory first so
+// an interrupted resize doesn't leave a mix of sizes behind.
+func resizeFrames(ctx context.Context, src, dst string, w, h int) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("error reading frames directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".png" {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no PNG frames in %s", src)
+	}
+
+	dst = filepath.Clean(dst)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), filepath.Base(dst)+"-partial-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary frames directory: %w", err)
+	}
+
+	// Resize on every core, stopping at the first error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan string)
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				if err := resizeFrame(filepath.Join(src, name), filepath.Join(tmp, name), w, h); err != nil {
+					once.Do(func() { firstErr = err })
+					cancel()
+				}
+			}
+		}()
+	}
+	for _, name := range names {
+		select {
+		case jobs <- name:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = fmt.Errorf("interrupted")
+	}
+	if firstErr != nil {
+		if keepPartial {
+			fmt.Printf("Partial frames kept in %s\n", tmp)
+		} else {
+			os.RemoveAll(tmp)
+		}
+		return firstErr
+	}
+	// Deduplicated sets keep their manifest and variable frame rate sets
+	// their timestamps, the file names and frame times don't change
+	for _, name := range []string{manifestName, timestampsName} {
+		data, err := os.ReadFile(filepath.Join(src, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(tmp, name), data, 0o644)
+		}
+		if err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("error copying %s: %w", name, err)
+		}
+	}
+	fmt.Printf("Resized %d frames to %dx%d\n", len(names), w, h)
+	return replaceDir(tmp, dst)
+}
+
+// resizeFrame rescales one PNG frame to a grayscale PNG of w x h
+func resizeFrame(src, dst string, w, h int) error {
+	img, err := decodeFrame(src)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, ScaleGray(img, w, h)); err != nil {
+		out.Close()
+		return fmt.Errorf("error encoding %s: %w", dst, err)
+	}
+	return out.Close()
+}
+
+// ScaleGray resamples img to w x h, averaging every source pixel a target
+// pixel covers when shrinking so fine detail doesn't alias
+func ScaleGray(img *image.Gray, w, h int) *image.Gray {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	scaled := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := y * srcH / h
+		y1 := max(y0+1, (y+1)*srcH/h)
+		for x := range w {
+			x0 := x * srcW / w
+			x1 := max(x0+1, (x+1)*srcW/w)
+			sum := 0
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[img.PixOffset(b.Min.X, b.Min.Y+sy):]
+				for sx := x0; sx < x1; sx++ {
+					sum += int(row[sx])
+				}
+			}
+			scaled.Pix[y*scaled.Stride+x] = uint8(sum / ((y1 - y0) * (x1 - x0)))
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Supported terminal graphics protocols
+const (
+	graphicsSixel = "sixel"
+	graphicsKitty = "kitty"
+)
+
+const (
+	// Assumed pixel size of a terminal cell. Terminals don't reliably report
+	// it (and never over SSH), so images are sized for a typical font.
+	cellPixelWidth  = 8
+	cellPixelHeight = 16
+
+	// Number of gray levels in the sixel palette
+	sixelLevels = 16
+
+	// Maximum payload size of a single kitty graphics escape sequence
+	kittyChunkSize = 4096
+)
+
+// detectGraphics returns the requested graphics mode if the terminal looks
+// like it supports it, or "" to fall back to ASCII
+func detectGraphics(mode, term string, environ []string) string {
+	if mode == "" {
+		return ""
+	}
+
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+
+	supported := false
+	switch mode {
+	case graphicsKitty:
+		supported = strings.Contains(term, "kitty") ||
+			env["KITTY_WINDOW_ID"] != "" ||
+			env["TERM_PROGRAM"] == "ghostty" ||
+			env["TERM_PROGRAM"] == "WezTerm"
+	case graphicsSixel:
+		// kitty sets TERM=xterm-kitty but has no sixel support
+		if strings.Contains(term, "kitty") {
+			break
+		}
+		for _, t := range []string{"foot", "xterm", "mlterm", "contour", "wezterm"} {
+			if strings.Contains(term, t) {
+				supported = true
+				break
+			}
+		}
+		supported = supported || env["TERM_PROGRAM"] == "WezTerm"
+	}
+
+	if !supported {
+		log.Warn("terminal does not appear to support graphics, falling back to ASCII", "mode", mode, "term", term)
+		return ""
+	}
+	return mode
+}
+
+// scaleGray resizes a grayscale image with nearest neighbor sampling
+func scaleGray(img *image.Gray, width, height int) *image.Gray {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		srcY := b.Min.Y + (y*srcH)/height
+		for x := 0; x < width; x++ {
+			srcX := b.Min.X + (x*srcW)/width
+			dst.Pix[y*dst.Stride+x] = img.GrayAt(srcX, srcY).Y
+		}
+	}
+	return dst
+}
+
+// encodeSixel encodes a frame as a sixel image covering cols x rows cells.
+// The cursor is saved and restored around the image and the result is padded
+// with newlines so it occupies the same lines as an ASCII frame.
+func encodeSixel(img *image.Gray, cols, rows int) string {
+	scaled := scaleGray(img, cols*cellPixelWidth, rows*cellPixelHeight)
+	w, h := scaled.Rect.Dx(), scaled.Rect.Dy()
+
+	var sb strings.Builder
+	sb.WriteString("\x1b7")       // Save cursor
+	sb.WriteString("\x1bP0;1;0q") // Start sixel, zero bits stay transparent
+	fmt.Fprintf(&sb, "\"1;1;%d;%d", w, h)
+
+	// Grayscale palette, intensities are percentages
+	for i := 0; i < sixelLevels; i++ {
+		pct := i * 100 / (sixelLevels - 1)
+		fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", i, pct, pct, pct)
+	}
+
+	// Each band is six pixel rows. For every gray level present in the band,
+	// emit one pass of sixels and return to the band start with '$'.
+	bits := make([][]byte, sixelLevels)
+	for band := 0; band < h; band += 6 {
+		for i := range bits {
+			bits[i] = nil
+		}
+		for dy := 0; dy < 6 && band+dy < h; dy++ {
+			row := scaled.Pix[(band+dy)*scaled.Stride:]
+			for x := 0; x < w; x++ {
+				level := (int(row[x])*(sixelLevels-1) + 127) / 255
+				if bits[level] == nil {
+					bits[level] = make([]byte, w)
+				}
+				bits[level][x] |= 1 << dy
+			}
+		}
+
+		first := true
+		for level, levelBits := range bits {
+			if levelBits == nil {
+				continue
+			}
+			if !first {
+				sb.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&sb, "#%d", level)
+			writeSixelRuns(&sb, levelBits)
+		}
+		sb.WriteByte('-')
+	}
+
+	sb.WriteString("\x1b\\") // End sixel
+	sb.WriteString("\x1b8")  // Restore cursor
+	sb.WriteString(strings.Repeat("\n", rows-1))
+	return sb.String()
+}
+
+// writeSixelRuns writes sixel data using run-length encoding
+func writeSixelRuns(sb *strings.Builder, bits []byte) {
+	for i := 0; i < len(bits); {
+		j := i
+		for j < len(bits) && bits[j] == bits[i] {
+			j++
+		}
+		c := byte('?' + bits[i])
+		if n := j - i; n > 3 {
+			fmt.Fprintf(sb, "!%d%c", n, c)
+		} else {
+			for k := 0; k < n; k++ {
+				sb.WriteByte(c)
+			}
+		}
+		i = j
+	}
+}
+
+// encodeKitty encodes a frame with the kitty graphics protocol, scaled by the
+// terminal to cols x rows cells. Reusing the same image id replaces the
+// previous frame instead of stacking images.
+func encodeKitty(img *image.Gray, cols, rows int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("error encoding frame: %w", err)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var sb strings.Builder
+	for i := 0; i < len(data); i += kittyChunkSize {
+		end := min(i+kittyChunkSize, len(data))
+		more := 1
+		if end == len(data) {
+			more = 0
+		}
+		if i == 0 {
+			// C=1 keeps the cursor in place, q=2 suppresses terminal replies
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,i=1,p=1,q=2,C=1,c=%d,r=%d,m=%d;", cols, rows, more)
+		} else {
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;", more)
+		}
+		sb.WriteString(data[i:end])
+		sb.WriteString("\x1b\\")
+	}
+	sb.WriteString(strings.Repeat("\n", rows-1))
+	return sb.String(), nil
+}
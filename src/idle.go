@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// idleNotice is how long before an idle disconnect the notice is shown
+const idleNotice = time.Minute
+
+// idleCheckMsg is sent periodically to check for inactivity
+type idleCheckMsg time.Time
+
+// idleModel wraps a session's model and quits after timeout without a key
+// press, so abandoned SSH sessions don't keep a player running
+type idleModel struct {
+	model        tea.Model
+	timeout      time.Duration
+	lastActivity time.Time
+	width        int
+	height       int
+}
+
+// newIdleModel wraps model with an idle timeout
+func newIdleModel(model tea.Model, timeout time.Duration) idleModel {
+	return idleModel{model: model, timeout: timeout, lastActivity: time.Now()}
+}
+
+// Init initializes the wrapped model and starts checking for inactivity
+func (m idleModel) Init() tea.Cmd {
+	return tea.Batch(m.model.Init(), idleCheck())
+}
+
+// Update tracks key presses and passes every message to the wrapped model
+func (m idleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case idleCheckMsg:
+		if m.remaining() <= 0 {
+			return m, tea.Quit
+		}
+		return m, idleCheck()
+	case tea.KeyMsg:
+		notified := m.remaining() <= idleNotice
+		m.lastActivity = time.Now()
+		if notified {
+			// The key only dismisses the notice
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	}
+
+	var cmd tea.Cmd
+	m.model, cmd = m.model.Update(msg)
+	return m, cmd
+}
+
+// View shows a notice before disconnecting, or the wrapped model
+func (m idleModel) View() string {
+	remaining := m.remaining()
+	if remaining > idleNotice {
+		return m.model.View()
+	}
+	notice := fmt.Sprintf("Disconnecting in %ds due to inactivity.\nPress any key to stay.",
+		int(max(0, remaining).Seconds()))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, notice)
+}
+
+// remaining returns the time left before an idle disconnect
+func (m idleModel) remaining() time.Duration {
+	return m.timeout - time.Since(m.lastActivity)
+}
+
+// idleCheck schedules the next inactivity check
+func idleCheck() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return idleCheckMsg(t)
+	})
+}
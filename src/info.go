@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// videoInfo describes a frame set and its audio and subtitles for -info
+type videoInfo struct {
+	Frames       int            `json:"frames"`
+	Width        int            `json:"width"`
+	Height       int            `json:"height"`
+	FPS          float64        `json:"fps"`
+	VariableRate bool           `json:"variable_frame_rate"`
+	Duration     float64        `json:"duration_seconds"`
+	Audio        string         `json:"audio,omitempty"`
+	AudioLength  float64        `json:"audio_duration_seconds,omitempty"`
+	Subtitles    map[string]int `json:"subtitle_cues"`
+}
+
+// collectInfo gathers what -info reports about the open frame set. Only the
+// first frame is decoded and the audio is scanned without playing it.
+func collectInfo(frameCount int) (videoInfo, error) {
+	info := videoInfo{
+		Frames:       frameCount,
+		VariableRate: frameTimestamps != nil,
+		Duration:     frameTime(frameCount).Seconds(),
+		Subtitles:    map[string]int{},
+	}
+	first, err := loadFrame(1, nil)
+	if err != nil {
+		return info, err
+	}
+	info.Width, info.Height = first.Bounds().Dx(), first.Bounds().Dy()
+	if info.Duration > 0 {
+		// The average rate for variable frame rate sources
+		info.FPS = float64(frameCount) / info.Duration
+	}
+
+	if audioFile != "" {
+		if length, err := audioDuration(audioFile); err == nil {
+			info.Audio = audioFile
+			info.AudioLength = length.Seconds()
+		} else if !os.IsNotExist(err) {
+			return info, err
+		}
+	}
+	for _, lang := range []string{"ja", "en"} {
+		subs, err := loadSubtitles(lang)
+		if err != nil {
+			return info, err
+		}
+		info.Subtitles[lang] = len(subs)
+	}
+	return info, nil
+}
+
+// audioDuration returns how long an MP3 file plays
+func audioDuration(path string) (time.Duration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	decoder, err := mp3.NewDecoder(file)
+	if err != nil {
+		return 0, fmt.Errorf("error decoding MP3: %w", err)
+	}
+	samples := decoder.Length() / audioFrameSize
+	return time.Duration(samples) * time.Second / time.Duration(decoder.SampleRate()), nil
+}
+
+// printInfo prints what collectInfo found, as JSON if asJSON
+func printInfo(info videoInfo, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	rate := fmt.Sprintf("%.2f FPS", info.FPS)
+	if info.VariableRate {
+		rate += " average, variable"
+	}
+	fmt.Printf("Frames:    %d\n", info.Frames)
+	fmt.Printf("Size:      %dx%d\n", info.Width, info.Height)
+	fmt.Printf("Rate:      %s\n", rate)
+	fmt.Printf("Duration:  %s\n", formatTimestamp(time.Duration(info.Duration*float64(time.Second))))
+	if info.Audio != "" {
+		fmt.Printf("Audio:     %s, %s\n", info.Audio, formatTimestamp(time.Duration(info.AudioLength*float64(time.Second))))
+	} else {
+		fmt.Println("Audio:     none")
+	}
+	fmt.Printf("Subtitles: ja %d cues, en %d cues\n", info.Subtitles["ja"], info.Subtitles["en"])
+	return nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// FrameMsg adds a frame rendered outside the player, like a generated test
+// pattern, after the frames loaded so far. It's the public form of the
+// frameLoadedMsg the background loader sends: the frame is shown as is, so
+// it should already fit the video size. Injected frames aren't tied to a
+// load, so a resize that re-renders frames from frames/ replaces them.
+type FrameMsg string
+
+// SendFrame injects a frame into a running player
+func SendFrame(p *tea.Program, frame string) {
+	p.Send(FrameMsg(frame))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Actions that can be bound to keys
+const (
+	actionPlayPause    = "play_pause"
+	actionReset        = "reset"
+	actionSubtitles    = "subtitles"
+	actionSearch       = "search"
+	actionSeekForward  = "seek_forward"
+	actionSeekBackward = "seek_backward"
+	actionDebug        = "debug"
+	actionSettings     = "settings"
+	actionNext         = "next"
+	actionPrevious     = "previous"
+	actionSubsEarlier  = "subs_earlier"
+	actionSubsLater    = "subs_later"
+	actionAudioEarlier = "audio_earlier"
+	actionAudioLater   = "audio_later"
+	actionQuit         = "quit"
+)
+
+// defaultBindings lists the keys for each action, first key shown in hints
+var defaultBindings = map[string][]string{
+	actionPlayPause:    {" "},
+	actionReset:        {"r"},
+	actionSubtitles:    {"s"},
+	actionSearch:       {"/"},
+	actionSeekForward:  {"right"},
+	actionSeekBackward: {"left"},
+	actionDebug:        {"d"},
+	actionSettings:     {"esc"},
+	actionNext:         {"n"},
+	actionPrevious:     {"p"},
+	actionSubsEarlier:  {"["},
+	actionSubsLater:    {"]"},
+	actionAudioEarlier: {"{"},
+	actionAudioLater:   {"}"},
+	actionQuit:         {"q", "ctrl+c"},
+}
+
+// keyMap maps bubbletea key strings to actions
+type keyMap struct {
+	actions  map[string]string
+	bindings map[string][]string
+}
+
+// newKeyMap builds a key map from per-action key lists
+func newKeyMap(bindings map[string][]string) keyMap {
+	km := keyMap{
+		actions:  make(map[string]string),
+		bindings: bindings,
+	}
+	for action, keys := range bindings {
+		for _, key := range keys {
+			km.actions[key] = action
+		}
+	}
+	return km
+}
+
+// action returns the action bound to a key, or ""
+func (km keyMap) action(key string) string {
+	return km.actions[key]
+}
+
+// hint returns the display name of the first key bound to an action
+func (km keyMap) hint(action string) string {
+	keys := km.bindings[action]
+	if len(keys) == 0 {
+		return "unbound"
+	}
+	return keyName(keys[0])
+}
+
+// keyName returns the name a key is written as in keybinding files
+func keyName(key string) string {
+	if key == " " {
+		return "space"
+	}
+	return key
+}
+
+// loadKeyMap reads keybindings from a file of "action = key, key" lines.
+// Actions missing from the file keep their default keys, less any keys the
+// file binds to other actions. A key bound to two actions is an error.
+func loadKeyMap(path string) (keyMap, error) {
+	bindings := make(map[string][]string, len(defaultBindings))
+	for action, keys := range defaultBindings {
+		bindings[action] = keys
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return keyMap{}, fmt.Errorf("error opening keybindings: %w", err)
+	}
+	defer file.Close()
+
+	// The line each action is bound on, the last if more than one
+	lines := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		action, keyList, ok := strings.Cut(line, "=")
+		action = strings.TrimSpace(action)
+		if _, known := defaultBindings[action]; !ok || !known {
+			return keyMap{}, fmt.Errorf("%s:%d: expected \"action = key, ...\" with a known action", path, lineNum)
+		}
+
+		var keys []string
+		for _, key := range strings.Split(keyList, ",") {
+			key = strings.TrimSpace(key)
+			if key == "space" {
+				key = " "
+			}
+			if key != "" {
+				keys = append(keys, key)
+			}
+		}
+		bindings[action] = keys
+		lines[action] = lineNum
+	}
+	if err := scanner.Err(); err != nil {
+		return keyMap{}, fmt.Errorf("error reading keybindings: %w", err)
+	}
+
+	// Check the file's bindings in file order, so the later of two lines
+	// sharing a key is the one reported
+	rebound := slices.SortedFunc(maps.Keys(lines), func(a, b string) int { return lines[a] - lines[b] })
+	owners := make(map[string]string)
+	for _, action := range rebound {
+		for _, key := range bindings[action] {
+			if owner, ok := owners[key]; ok && owner != action {
+				return keyMap{}, fmt.Errorf("%s:%d: %s is already bound to %s on line %d",
+					path, lines[action], keyName(key), owner, lines[owner])
+			}
+			owners[key] = action
+		}
+	}
+	// Keys taken by the file are no longer bound to their default actions
+	for action, keys := range bindings {
+		if _, ok := lines[action]; !ok {
+			bindings[action] = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+				_, taken := owners[key]
+				return taken
+			})
+		}
+	}
+
+	return newKeyMap(bindings), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeKeys writes a keybindings file and returns its path
+func writeKeys(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.conf")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyMap(t *testing.T) {
+	path := writeKeys(t, "# swap pause and quit onto single keys\n\nplay_pause = space, q\nquit = x\n")
+	km, err := loadKeyMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		" ":      actionPlayPause,
+		"q":      actionPlayPause,
+		"x":      actionQuit,
+		"ctrl+c": "",
+		"r":      actionReset,
+	} {
+		if got := km.action(key); got != want {
+			t.Errorf("action(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	// A default action gives up keys the file binds elsewhere, and keeps
+	// the rest
+	path = writeKeys(t, "play_pause = space, p\n")
+	km, err = loadKeyMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := km.action("p"); got != actionPlayPause {
+		t.Errorf("action(p) = %q, want %q", got, actionPlayPause)
+	}
+	if keys := km.bindings[actionPrevious]; len(keys) != 0 {
+		t.Errorf("previous still bound to %q after p was rebound", keys)
+	}
+	if got := km.hint(actionPrevious); got != "unbound" {
+		t.Errorf("hint(previous) = %q, want unbound", got)
+	}
+	if keys := km.bindings[actionQuit]; !slices.Equal(keys, []string{"q", "ctrl+c"}) {
+		t.Errorf("quit bound to %q, want its defaults", keys)
+	}
+	if keys := defaultBindings[actionPrevious]; !slices.Equal(keys, []string{"p"}) {
+		t.Errorf("default bindings changed to %q", keys)
+	}
+}
+
+func TestLoadKeyMapErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"pause = q\n", ":1: expected"},
+		{"reset = r\nno equals sign\n", ":2: expected"},
+		{"reset = r\n\nquit = x, r\n", ":3: r is already bound to reset on line 1"},
+		{"seek_forward = space\nplay_pause = space\n", ":2: space is already bound to seek_forward on line 1"},
+	} {
+		path := writeKeys(t, tt.src)
+		_, err := loadKeyMap(path)
+		if err == nil || !strings.Contains(err.Error(), path+tt.want) {
+			t.Errorf("loadKeyMap(%q) error = %v, want %q", tt.src, err, path+tt.want)
+		}
+	}
+}
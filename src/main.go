@@ -8,41 +8,109 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"math"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/activeterm"
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
+	"github.com/charmbracelet/x/ansi"
+	gossh "golang.org/x/crypto/ssh"
+
+	"senshukai/badz"
+	"senshukai/render"
 )
 
 // Model represents the application state
 type Model struct {
-	frames          []string
-	currentFrame    int
-	frameCount      int
-	playing         bool
-	lastUpdate      time.Time
-	width           int
-	height          int
-	loading         bool
-	frameChan       chan string
-	audioStarted    bool
-	audioPlayer     *AudioPlayer
-	audioEnabled    bool
-	subtitlesJA     []Subtitle
-	subtitlesEN     []Subtitle
-	subtitleMode    int // 0: off, 1: JA, 2: EN
-	currentSubtitle string
-	showControls    bool
+	frames        []string
+	currentFrame  int
+	frameCount    int
+	totalFrames   int // 0 until background loading completes
+	buffering     bool
+	playing       bool
+	lastUpdate    time.Time
+	clock         *frameClock // ticks the player, or nil to tick with commands
+	nextTick      time.Time   // when the clock's next tick is due
+	width         int
+	height        int
+	loading       bool
+	frameChan     chan loadedFrame
+	ctx           context.Context    // cancelled when the player stops, ending all loading
+	cancelLoading context.CancelFunc // stops the in-flight load on reload or quit
+	loadGen       int                // incremented on every reload to drop stale messages
+	loaded        int                // frames rendered at the current video size
+	videoWidth    int
+	videoHeight   int
+	prefetch      int
+	graphics      string // "" for ASCII, or a graphics protocol
+	halfblock     bool
+	subpixel      bool
+	crt           crtEffect
+	threshold     int // half-block black and white cutoff, or thresholdOff or thresholdAuto
+	fit           string
+	background    color.Color // composite transparent frames over this, or nil
+	lightTerm     bool        // the terminal has a light background
+	plain         bool        // draw without escape sequences, for plainOutput
+	asciiSafe     bool        // draw ASCII frames with render.ASCIIRamp
+	crop          image.Rectangle
+	clipStart     int // frames skipped before the clip, for -from
+	speedRamp     []speedPoint
+	rampOff       bool // speed ramp turned off in the settings panel
+	frameStep     int  // frames advanced per tick, above 1 to lower the frame rate
+	subFrame      int  // in-between frame of the current frame being shown, 0 for the frame itself
+	interpolate   bool
+	volume        float64
+	loopPause     time.Duration // how long to hold the last frame before looping
+	loopResumeAt  time.Time     // end of the current loop pause, zero when not pausing
+	audioMuted    bool          // audio paused while the speed ramp isn't at 1x
+	audioStarted  bool
+	audioPlayer   AudioController
+	audioStall    chan time.Duration // audio positions reported on stalls
+	showDebug     bool
+	lastTiming    frameTiming   // render time of the last loaded frame
+	tickInterval  time.Duration // time between the last two ticks
+	maxMemory     int64         // bytes of frames to keep before evicting, 0 for no limit
+	rendering     map[int]bool  // evicted frames being rendered again
+	stats         playbackStats
+	audioEnabled  bool
+	subtitlesJA   []Subtitle
+	subtitlesEN   []Subtitle
+	subtitleMode  int           // 0: off, 1: JA, 2: EN
+	subOffset     time.Duration // how much later than their times cues are shown
+	audioDelay    time.Duration // how far audio plays ahead of the video, for output latency
+	currentCues   []Subtitle    // all cues active at the current frame
+	lastCue       int           // index of the first shown cue, a hint for the next lookup
+	subtitleStyle subtitleStyle
+	border        string // -border style around the video
+	borderTitle   string
+	osdVisible    bool
+	osdGen        int           // incremented each time the OSD is shown to drop stale hides
+	clipFrames    int           // frames in the clip, for the OSD before loading completes
+	hold          bool          // -repeat-frame holds the frame, never playing
+	resumeAt      time.Duration // where a returning SSH viewer left off, 0 once applied
+	playlistIndex int           // entry of the -playlist being played
+	crossfade     time.Duration // how long playlist entries blend into each other
+	fadeFrom      []string      // last frames of the previous entry, blended into this one's first
+	search        searchState
+	settings      settingsPanel
+	keys          keyMap
+	theme         Theme
+	once          bool // quit after one play-through instead of looping
+	syncLoad      bool // load every frame before playing, without a background loader
+	noVideo       bool // show an audio visualizer instead of frames
+	beat          bool // pulse rules above and below the video on audio onsets
 }
 
 // Init initializes the model
@@ -54,78 +122,186 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
-			// Clean up audio player
-			if m.audioPlayer != nil {
-				m.audioPlayer.Close()
+		if m.search.active {
+			return m.updateSearch(msg)
+		}
+		if m.settings.active && msg.String() != "ctrl+c" {
+			var cmd tea.Cmd
+			m.settings, cmd = m.settings.Update(msg)
+			return m, cmd
+		}
+		// Any key brings up the OSD
+		osd := m.showOSD()
+		model, cmd := m.updateKey(msg)
+		return model, tea.Batch(cmd, osd)
+	case settingChangedMsg:
+		return m, m.applySetting(msg)
+	case settingsClosedMsg:
+		return m, nil
+	case osdHideMsg:
+		if int(msg) == m.osdGen {
+			m.osdVisible = false
+		}
+		return m, nil
+	case tickMsg:
+		now := time.Time(msg)
+		m.tickInterval = now.Sub(m.lastUpdate)
+		m.lastUpdate = now
+		if m.playing && m.frameCount > 0 {
+			if m.frameStep == 1 && m.subFrame < tweens(m.frames[m.currentFrame]) {
+				// Show the next in-between frame of this one
+				m.subFrame++
+				return m, m.tick()
 			}
-			return m, tea.Quit
-		case " ":
-			// Toggle play/pause
-			m.playing = !m.playing
-			if m.audioPlayer != nil {
-				if m.playing {
-					if m.audioPlayer.IsPaused() {
-						m.audioPlayer.Resume()
-					} else {
-						m.audioPlayer.Play()
+			m.subFrame = 0
+			next := m.currentFrame + m.frameStep
+			if fade := m.fadeFrames(); fade > 0 && next >= m.clipFrames-fade {
+				// The rest of this video plays blended into the next one
+				return m, m.playEntry(m.playlistIndex+1, m.fadeSources(fade))
+			}
+			if next >= m.frameCount {
+				if m.totalFrames == 0 {
+					// Background loading hasn't caught up, hold this frame
+					m.buffering = true
+					return m, m.tick()
+				}
+				if m.hasNextEntry() {
+					return m, m.playEntry(m.playlistIndex+1, nil)
+				}
+				if m.once {
+					// Every frame has been shown exactly once
+					m.close()
+					return m, tea.Quit
+				}
+				if m.loopPause > 0 && m.loopResumeAt.IsZero() {
+					// Hold the last frame in silence before looping
+					m.loopResumeAt = time.Now().Add(m.loopPause)
+					if m.audioPlayer != nil && !m.audioMuted {
+						m.audioPlayer.Pause()
 					}
-				} else {
-					m.audioPlayer.Pause()
 				}
-			}
-			if m.playing {
-				return m, tick()
-			}
-			return m, nil
-		case "s":
-			// Cycle through subtitle modes
-			m.subtitleMode = (m.subtitleMode + 1) % 3
-			// Clear current subtitle when changing modes
-			m.currentSubtitle = ""
-			return m, nil
-		case "r":
-			// Reset to beginning
-			m.currentFrame = 0
-			if m.audioPlayer != nil {
-				m.audioPlayer.Stop()
-				if m.playing {
-					m.audioPlayer.Play()
+				if time.Now().Before(m.loopResumeAt) {
+					return m, m.tick()
 				}
+				// End of video, loop back to the start
+				next = 0
+				m.restartAudio()
 			}
-			return m, nil
-		}
-	case tickMsg:
-		if m.playing && m.frameCount > 0 {
-			m.currentFrame = (m.currentFrame + 1) % m.frameCount
+			if m.evicted(next) {
+				// Hold this frame until the evicted one is rendered again
+				m.buffering = true
+				return m, tea.Batch(m.tick(), m.refill())
+			}
+			m.buffering = false
+			m.currentFrame = next
+			m.stats.shown(m.tickInterval)
 			m.updateSubtitle()
-			// Also check for new frames from background loading
-			return m, tea.Batch(tick(), waitForFrame(m.frameChan))
+			m.muteForSpeed()
+			return m, tea.Batch(m.tick(), m.refill())
 		}
 	case framesLoadedMsg:
-		m.frames = msg.frames
-		m.frameCount = len(msg.frames)
+		if msg.gen != m.loadGen {
+			return m, nil
+		}
+		firstLoad := m.frameCount == 0
+		if firstLoad && len(msg.frames) == 0 {
+			// The first frame couldn't be rendered, nothing can play
+			log.Errorf("could not load the first frame of %s", frameDir)
+			m.close()
+			return m, tea.Quit
+		}
+		// Replace the start of any frames rendered at a previous size
+		m.frames = append(msg.frames, m.frames[min(len(msg.frames), len(m.frames)):]...)
+		m.loaded = len(msg.frames)
+		m.frameCount = len(m.frames)
+		m.resumeWhenLoaded()
+		if len(msg.timings) > 0 {
+			m.lastTiming = msg.timings[len(msg.timings)-1]
+		}
+		m.stats.rendered(msg.timings...)
+		m.evictFrames()
 		m.loading = true
-		// Auto-start playing when initial frames are loaded
-		m.playing = true
+		// Keep draining background frames unless everything was loaded
+		wait := waitForFrame(m.frameChan, m.loadGen)
+		if msg.complete {
+			m.finishLoading()
+			wait = nil
+		}
+		if !firstLoad {
+			return m, wait
+		}
+		// Auto-start playing when initial frames are loaded, unless the
+		// frame is held for -repeat-frame
+		m.playing = !m.hold
 		// Initialize audio player only if audio is enabled
+		var stall tea.Cmd
 		if m.audioEnabled && !m.audioStarted {
-			audioPlayer, err := NewAudioPlayer()
-			if err != nil {
-				fmt.Printf("Warning: Could not initialize audio: %v\n", err)
-			} else {
-				m.audioPlayer = audioPlayer
-				m.audioPlayer.Play()
-			}
-			m.audioStarted = true
+			stall = m.startAudio()
 		}
-		return m, tea.Batch(tick(), waitForFrame(m.frameChan))
+		// Hide the OSD shown since startup once playback is underway
+		osd := m.showOSD()
+		return m, tea.Batch(m.tick(), wait, osd, stall)
 
 	case frameLoadedMsg:
-		// Add frame from background loading
-		m.frames = append(m.frames, msg.frame)
+		if msg.gen != m.loadGen {
+			return m, nil
+		}
+		// Add frame from background loading and wait for the next one
+		if m.loaded < len(m.frames) {
+			m.frames[m.loaded] = msg.frame
+		} else {
+			m.frames = append(m.frames, msg.frame)
+		}
+		m.loaded++
+		m.frameCount = len(m.frames)
+		m.resumeWhenLoaded()
+		m.lastTiming = msg.timing
+		m.stats.rendered(msg.timing)
+		m.evictFrames()
+		return m, waitForFrame(m.frameChan, m.loadGen)
+	case scriptMsg:
+		return m.runScriptCommand(msg)
+	case FrameMsg:
+		if m.loaded < len(m.frames) {
+			m.frames[m.loaded] = string(msg)
+		} else {
+			m.frames = append(m.frames, string(msg))
+		}
+		m.loaded++
 		m.frameCount = len(m.frames)
+		// The first frame starts playback, like the first loaded batch
+		if m.frameCount == 1 {
+			return m, m.setPlaying(true)
+		}
+		return m, nil
+	case frameRenderedMsg:
+		if msg.gen != m.loadGen || msg.frame == "" {
+			// Failed renders stay marked so they aren't retried every tick
+			return m, nil
+		}
+		delete(m.rendering, msg.index)
+		if m.evicted(msg.index) {
+			m.frames[msg.index] = msg.frame
+			m.evictFrames()
+		}
+		return m, nil
+	case audioStallMsg:
+		if msg.stall != m.audioStall {
+			// Sent by audio that has since been stopped
+			return m, nil
+		}
+		// Audio stopped advancing while video kept going, bring the video
+		// back to where the audio is
+		m.stats.audioStalls++
+		frame := frameAt(msg.pos-m.audioDelay) - m.clipStart
+		m.currentFrame = max(0, min(frame, m.frameCount-1))
+		m.updateSubtitle()
+		return m, waitForStall(m.audioStall)
+	case loadingCompleteMsg:
+		if msg.gen != m.loadGen {
+			return m, nil
+		}
+		m.finishLoading()
 		return m, nil
 	case startLoadingMsg:
 		m.loading = true
@@ -134,420 +310,1877 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// Start loading frames when we know the terminal size
-		if m.frameCount == 0 && !m.loading {
-			// Always reserve 3 lines for subtitles
-			videoHeight := m.height - 3
-			if videoHeight < 1 {
-				videoHeight = 1
-			}
-			return m, tea.Batch(
-				loadInitialFrames(m.width, videoHeight),
-				listenForFrames(m.frameChan, m.width, videoHeight),
-			)
-		}
-		return m, nil
+		// Start loading frames, or re-render them for the new size
+		return m, m.layout()
 	}
 	return m, nil
 }
 
 // View renders the model
 func (m Model) View() string {
+	if m.search.active {
+		return m.searchView()
+	}
+	if m.settings.active && m.graphics != "" {
+		// Graphics can't be drawn over, so the panel takes the screen
+		return m.settings.View()
+	}
 	if m.frameCount == 0 {
-		return "Loading frames...\nPress 'q' to quit, 'space' to play/pause, 'r' to reset, 's' for subtitles"
+		return fmt.Sprintf("Loading frames...\nPress '%s' to quit, '%s' to play/pause, '%s' to reset, '%s' for subtitles",
+			m.keys.hint(actionQuit), m.keys.hint(actionPlayPause),
+			m.keys.hint(actionReset), m.keys.hint(actionSubtitles))
 	}
 
-	var view strings.Builder
-	if m.currentFrame < len(m.frames) {
-		view.WriteString(m.frames[m.currentFrame])
-	} else {
-		view.WriteString("No frame to display")
+	frame := "No frame to display"
+	if m.noVideo {
+		frame = m.visualizer()
+	} else if m.currentFrame < len(m.frames) {
+		frame = tweenFrame(m.frames[m.currentFrame], m.subFrame)
 	}
+	if m.beat {
+		rule := m.beatRule()
+		frame = rule + "\n" + frame + "\n" + rule
+	}
+	frame = m.withBorder(frame)
 
-	// Add subtitle or controls to view
-	if m.subtitleMode > 0 && m.currentSubtitle != "" {
-		view.WriteString("\n\n")
-
-		// Split subtitle into lines and center each line
-		lines := strings.Split(m.currentSubtitle, "\n")
-		for _, line := range lines {
-			// Trim whitespace from the line
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
+	// Subtitles, status or controls shown in the reserved rows
+	var caption string
+	showSubtitle := m.subtitleMode > 0 && len(m.currentCues) > 0
+	showOSD := m.osdVisible && m.graphics != ""
+	switch {
+	case showOSD:
+		// Graphics can't be drawn over, so the OSD takes the caption rows
+		caption = m.osd()
+	case showSubtitle:
+		caption = m.renderSubtitles()
+	case m.buffering && m.captionRows() > 0:
+		caption = lipgloss.PlaceHorizontal(m.width, lipgloss.Center, m.theme.Status.Render("buffering..."))
+	}
+	// With subtitles on their rows are always drawn, blank between cues
+	subtitleRows := m.subtitleMode > 0 && !showOSD
+	if subtitleRows {
+		caption = m.anchorCaption(caption)
+	}
 
-			// Calculate padding for centering
-			padding := (m.width - len(line)) / 2
-			if padding < 0 {
-				padding = 0
-			}
+	// The frame is joined as plain text rather than with lipgloss: graphics
+	// escapes have no measurable width, so padding them would draw over the image
+	view := frame
+	switch {
+	case caption == "" && !subtitleRows:
+	case subtitleRows && m.subtitleStyle.position == subtitlePositionTop:
+		view = caption + "\n\n" + frame
+	default:
+		view = frame + "\n\n" + caption + "\n"
+	}
+	switch {
+	case m.settings.active:
+		view = overlayBottom(view, m.settings.View(), m.height)
+	case m.osdVisible && m.graphics == "":
+		view = overlayBottom(view, m.osd(), m.height)
+	}
+	view = m.withDebug(view)
+	if m.plain {
+		// Styles already have no color, this drops bold and the like
+		view = ansi.Strip(view)
+	}
+	return view
+}
 
-			view.WriteString(strings.Repeat(" ", padding))
-			view.WriteString(line)
-			view.WriteString("\n")
+// updateKey runs the action bound to a key
+func (m Model) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action := m.keys.action(msg.String())
+	// ctrl+c always quits, whatever the bindings
+	if msg.String() == "ctrl+c" {
+		action = actionQuit
+	}
+	switch action {
+	case actionQuit:
+		m.close()
+		return m, tea.Quit
+	case actionPlayPause:
+		// Toggle play/pause
+		return m, m.setPlaying(!m.playing)
+	case actionSearch:
+		// Open subtitle search
+		m.openSearch()
+		return m, nil
+	case actionSeekForward:
+		m.seekTo(m.videoTime() + seekStep)
+		return m, m.refill()
+	case actionSeekBackward:
+		m.seekTo(m.videoTime() - seekStep)
+		return m, m.refill()
+	case actionSubtitles:
+		// Cycle through subtitle modes
+		m.subtitleMode = (m.subtitleMode + 1) % 3
+		// Clear current subtitle when changing modes
+		m.currentCues = nil
+		return m, m.layout()
+	case actionDebug:
+		m.showDebug = !m.showDebug
+		return m, nil
+	case actionSettings:
+		m.openSettings()
+		return m, nil
+	case actionSubsEarlier, actionSubsLater:
+		if action == actionSubsEarlier {
+			m.subOffset -= subOffsetStep
+		} else {
+			m.subOffset += subOffsetStep
 		}
-	} else if m.showControls {
-		view.WriteString("\n\n")
-
-		// Controls text
-		controls := []string{
-			"[space] play/pause | [r] reset | [s] subtitles | [q] quit",
+		m.updateSubtitle()
+		return m, nil
+	case actionAudioEarlier, actionAudioLater:
+		if action == actionAudioEarlier {
+			m.audioDelay += audioDelayStep
+		} else {
+			m.audioDelay -= audioDelayStep
 		}
-
-		// Always use dim style
-		style := "\033[2m"
-
-		// Display controls with fade
-		for _, line := range controls {
-			// Calculate padding for centering
-			padding := (m.width - len(line)) / 2
-			if padding < 0 {
-				padding = 0
+		if m.audioPlayer != nil && !m.audioMuted {
+			if err := m.audioPlayer.Seek(m.audioTime()); err != nil {
+				log.Errorf("could not seek audio: %v", err)
 			}
-
-			view.WriteString(strings.Repeat(" ", padding))
-			view.WriteString(style)
-			view.WriteString(line)
-			view.WriteString("\033[0m") // Reset style
-			view.WriteString("\n")
 		}
+		return m, nil
+	case actionNext:
+		return m, m.playEntry(m.playlistIndex+1, nil)
+	case actionPrevious:
+		return m, m.playEntry(m.playlistIndex-1, nil)
+	case actionReset:
+		// Reset to beginning
+		m.currentFrame = 0
+		m.restartAudio()
+		return m, m.refill()
 	}
+	return m, nil
+}
 
-	return view.String()
+// withDebug draws the debug overlay over the view when it's toggled on
+func (m Model) withDebug(view string) string {
+	if !m.showDebug {
+		return view
+	}
+	if m.graphics != "" {
+		// Graphics escapes can't be cut, so the overlay goes above the image
+		return m.debugOverlay() + "\n" + view
+	}
+	return overlayTopRight(view, m.debugOverlay(), m.width)
 }
 
+// displayInterval is the display time of one tick at 60 FPS, ~16ms
+const displayInterval = (1000 / 60) * time.Millisecond
+
+// frameDuration is the display time of one frame unless frames/ has
+// timestamps, one display tick unless -source-fps is set
+var frameDuration = displayInterval
+
+// seekStep is how far the seek keys jump
+const seekStep = 5 * time.Second
+
+// subOffsetStep is how far the subtitle timing keys move cues
+const subOffsetStep = 100 * time.Millisecond
+
+// audioDelayStep is how far the audio delay keys move the audio
+const audioDelayStep = 20 * time.Millisecond
+
 // Messages
 type tickMsg time.Time
 type framesLoadedMsg struct {
-	frames []string
+	frames   []string
+	gen      int
+	complete bool // every frame was loaded, nothing follows in the background
+	timings  []frameTiming
 }
 
 type frameLoadedMsg struct {
-	frame string
+	frame  string
+	gen    int
+	timing frameTiming
+}
+
+// loadedFrame is a frame rendered in the background
+type loadedFrame struct {
+	frame  string
+	timing frameTiming
+}
+
+// loadingCompleteMsg is sent once background loading closes the frame channel
+type loadingCompleteMsg struct {
+	gen int
 }
 type startLoadingMsg struct{}
 
+// audioStallMsg reports where audio stalled, from the stall channel of the
+// audio player that sent it
+type audioStallMsg struct {
+	pos   time.Duration
+	stall chan time.Duration
+}
+
 // Commands
-func tick() tea.Cmd {
+func tick(d time.Duration) tea.Cmd {
 	return func() tea.Msg {
-		time.Sleep(16 * time.Millisecond) // ~60 FPS (1000ms / 60 ≈ 16.67ms)
+		time.Sleep(d)
 		return tickMsg(time.Now())
 	}
 }
 
-func loadInitialFrames(width, height int) tea.Cmd {
+// tick waits out the current frame, ~16ms at 60 FPS unless frames/ has
+// timestamps, or the frames skipped after it at a lower frame rate. A single
+// frame is shown as a still without ticking, unless it's shown once and the
+// tick ends playback.
+func (m *Model) tick() tea.Cmd {
+	if m.still() && !m.once {
+		return nil
+	}
+	i := m.clipStart + m.currentFrame
+	// Slower or faster for -speed-ramp
+	d := time.Duration(float64(frameTime(i+m.frameStep)-frameTime(i)) / m.speed())
+	if m.frameStep == 1 && m.currentFrame < len(m.frames) {
+		// In-between frames split the frame's time
+		d /= time.Duration(1 + tweens(m.frames[m.currentFrame]))
+	}
+	if !m.clock.started() {
+		return tick(d)
+	}
+	// Follow on from the last tick's time, unless playback was paused or
+	// fell behind by more than a frame
+	next := m.nextTick.Add(d)
+	if now := time.Now(); next.Before(now.Add(-d)) {
+		next = now.Add(d)
+	}
+	m.nextTick = next
+	m.clock.at(next)
+	return nil
+}
+
+func loadInitialFrames(opts renderOptions, prefetch, gen int) tea.Cmd {
+	return func() tea.Msg {
+		// Load the first few frames quickly to start playing
+		if clipTo > 0 {
+			prefetch = min(prefetch, clipTo-clipFrom+1)
+		}
+		frames := make([]string, 0, prefetch)
+		timings := make([]frameTiming, 0, prefetch)
+		for i := 1; i <= prefetch; i++ {
+			frame, timing, err := renderFrame(i, opts)
+			if err != nil {
+				break
+			}
+			frames = append(frames, frame)
+			timings = append(timings, timing)
+		}
+
+		return framesLoadedMsg{frames: frames, gen: gen, timings: timings}
+	}
+}
+
+// loadAllFrames renders every frame before playback starts, trading startup
+// time for deterministic playback
+func loadAllFrames(ctx context.Context, opts renderOptions, gen int) tea.Cmd {
 	return func() tea.Msg {
-		// Load first 30 frames quickly to start playing
-		frames := make([]string, 0)
-		for i := 1; i <= 30; i++ {
-			filename := getFrameFilename(i)
-			frame, err := loadFrameAsASCII(filename, width, height)
+		totalFrames, err := countClipFrames()
+		if err != nil {
+			fmt.Printf("Error counting frames: %v\n", err)
+		}
+
+		frames := make([]string, 0, totalFrames)
+		timings := make([]frameTiming, 0, totalFrames)
+		for i := 1; i <= totalFrames; i++ {
+			if ctx.Err() != nil {
+				// Superseded by a reload or the player quit
+				return nil
+			}
+			frame, timing, err := renderFrame(i, opts)
 			if err != nil {
+				fmt.Printf("Error loading frame %d: %v\n", i, err)
 				break
 			}
 			frames = append(frames, frame)
+			timings = append(timings, timing)
 		}
 
-		return framesLoadedMsg{frames: frames}
+		return framesLoadedMsg{frames: frames, gen: gen, complete: true, timings: timings}
 	}
 }
 
-func listenForFrames(frameChan chan string, width, height int) tea.Cmd {
+func listenForFrames(ctx context.Context, frameChan chan loadedFrame, opts renderOptions, prefetch int) tea.Cmd {
 	return func() tea.Msg {
 		// Start background loading of remaining frames
-		go loadRemainingFrames(frameChan, width, height)
+		go loadRemainingFrames(ctx, frameChan, opts, prefetch)
 		return startLoadingMsg{}
 	}
 }
 
-func waitForFrame(frameChan chan string) tea.Cmd {
+// waitForFrame blocks until the next background frame arrives. It must be
+// re-issued after each frameLoadedMsg to keep draining the channel.
+func waitForFrame(frameChan chan loadedFrame, gen int) tea.Cmd {
 	return func() tea.Msg {
-		select {
-		case frame := <-frameChan:
-			return frameLoadedMsg{frame: frame}
-		default:
-			// No frame available, try again later
+		loaded, ok := <-frameChan
+		if !ok {
+			return loadingCompleteMsg{gen: gen}
+		}
+		return frameLoadedMsg{frame: loaded.frame, gen: gen, timing: loaded.timing}
+	}
+}
+
+// waitForStall blocks until the audio player reports a stall, or returns
+// nil once the player is stopped and stall is closed
+func waitForStall(stall chan time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		pos, ok := <-stall
+		if !ok {
 			return nil
 		}
+		return audioStallMsg{pos: pos, stall: stall}
 	}
 }
 
-func loadRemainingFrames(frameChan chan string, width, height int) {
+// loadRemainingFrames renders frames in the background until done or until
+// ctx is cancelled by a reload or quit
+func loadRemainingFrames(ctx context.Context, frameChan chan loadedFrame, opts renderOptions, prefetch int) {
+	defer recoverPanic()
 	// Get total frame count dynamically
-	totalFrames, err := countFrames()
+	totalFrames, err := countClipFrames()
 	if err != nil {
 		fmt.Printf("Error counting frames: %v\n", err)
 		close(frameChan)
 		return
 	}
 
-	// Load remaining frames starting from frame 31
-	for i := 31; i <= totalFrames; i++ {
-		filename := getFrameFilename(i)
-		frame, err := loadFrameAsASCII(filename, width, height)
+	// Load remaining frames starting after the prefetched ones
+	for i := prefetch + 1; i <= totalFrames; i++ {
+		if ctx.Err() != nil {
+			// Don't decode another frame nobody will read
+			break
+		}
+		frame, timing, err := renderFrame(i, opts)
 		if err != nil {
 			fmt.Printf("Error loading frame %d: %v\n", i, err)
 			break
 		}
-		frameChan <- frame
+		select {
+		case frameChan <- loadedFrame{frame: frame, timing: timing}:
+		case <-ctx.Done():
+			close(frameChan)
+			return
+		}
 	}
 	close(frameChan)
 }
 
-// loadFrameAsASCII loads a PNG frame and converts it to ASCII art
-func loadFrameAsASCII(filename string, targetWidth, targetHeight int) (string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return "", err
+// renderOptions controls how frame images are turned into terminal output
+type renderOptions struct {
+	width       int
+	height      int
+	graphics    string
+	halfblock   bool
+	subpixel    bool // eighth blocks and quadrants in two grays per cell
+	threshold   int  // half-block black and white cutoff, or thresholdOff or thresholdAuto
+	fit         string
+	bg          color.Color
+	noVideo     bool            // skip rendering, frames only keep time
+	lightTerm   bool            // flip ASCII shading for a light terminal background
+	crop        image.Rectangle // source region to show, or empty for all
+	fadeFrom    []string        // previous video's frames to fade from over the first frames
+	interpolate bool            // blend in-between frames for frames longer than a display tick
+	crt         crtEffect       // scanlines and tint over text frames
+	asciiSafe   bool            // draw ASCII frames with characters any font has
+}
+
+// Terminal background brightness, for -term-bg
+const (
+	termBackgroundAuto  = "auto" // ask the terminal with an OSC 11 query
+	termBackgroundDark  = "dark"
+	termBackgroundLight = "light"
+)
+
+// Ways to fit frames into the terminal
+const (
+	fitFill    = "fill"    // stretch to cover every cell
+	fitContain = "contain" // keep the aspect ratio and letterbox
+	fitInteger = "integer" // letterbox at a whole scale, set by -integer-scale
+)
+
+// renderFrame loads the frameNum-th frame of the clip and renders it with the
+// configured backend
+func renderFrame(frameNum int, opts renderOptions) (string, frameTiming, error) {
+	var timing frameTiming
+	if opts.noVideo {
+		return "", timing, nil
 	}
-	defer file.Close()
 
-	img, err := png.Decode(file)
+	start := time.Now()
+	if frame, ok := loadPrerendered(frameNum, opts); ok {
+		timing.decode = time.Since(start)
+		return opts.crt.apply(frame, opts.lightTerm), timing, nil
+	}
+	grayImg, err := sourceFrame(frameNum, opts)
 	if err != nil {
-		return "", err
-	}
-
-	// Convert to grayscale if needed
-	grayImg, ok := img.(*image.Gray)
-	if !ok {
-		// Convert to grayscale
-		bounds := img.Bounds()
-		grayImg = image.NewGray(bounds)
-		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-			for x := bounds.Min.X; x < bounds.Max.X; x++ {
-				grayImg.Set(x, y, img.At(x, y))
-			}
+		return "", timing, err
+	}
+	timing.decode = time.Since(start)
+	start = time.Now()
+	if frameNum <= len(opts.fadeFrom) {
+		if grayImg, err = fadeIn(grayImg, frameNum, opts); err != nil {
+			return "", timing, err
 		}
 	}
 
-	lines := renderBlocksScaled(grayImg, targetWidth, targetHeight)
-	return strings.Join(lines, "\n"), nil
+	frame, err := renderImage(grayImg, opts)
+	if err != nil {
+		return "", timing, err
+	}
+	if opts.interpolate {
+		frame, err = withTweens(frame, grayImg, frameNum, opts)
+		if err != nil {
+			return "", timing, err
+		}
+	}
+	timing.convert = time.Since(start)
+	return frame, timing, nil
 }
 
-func renderBlocksScaled(img image.Image, targetWidth, targetHeight int) []string {
-	b := img.Bounds()
-	srcW, srcH := b.Dx(), b.Dy()
-
-	// Determine if we need to scale down (terminal smaller than source)
-	scaleDown := targetWidth < srcW || targetHeight < srcH
-
-	var lines []string
-
-	if scaleDown {
-		// For downscaling, use simple nearest neighbor for better performance
-		for y := 0; y < targetHeight; y++ {
-			var sb strings.Builder
-			for x := 0; x < targetWidth; x++ {
-				// Map target coordinates to source coordinates
-				srcX := (x * srcW) / targetWidth
-				srcY := (y * srcH) / targetHeight
-
-				// Clamp to source bounds
-				if srcX >= srcW {
-					srcX = srcW - 1
-				}
-				if srcY >= srcH {
-					srcY = srcH - 1
-				}
+// renderImage renders a frame image with the configured backend, letterboxed
+// to the video size
+func renderImage(img *image.Gray, opts renderOptions) (string, error) {
+	width, height := opts.width, opts.height
+	switch opts.fit {
+	case fitContain:
+		b := img.Bounds()
+		width, height = containSize(b.Dx(), b.Dy(), opts.width, opts.height)
+	case fitInteger:
+		b := img.Bounds()
+		var step integerStep
+		width, height, step = integerSize(b.Dx(), b.Dy(), opts.width, opts.height)
+		if opts.graphics == "" {
+			// Text is drawn a sample per cell, so sample the pixels here
+			// rather than leave the renderers to blend them
+			img = scaleNearest(img, width, height, step)
+		}
+	}
 
-				pixel := img.At(srcX, srcY).(color.Gray).Y
-				sb.WriteRune(pixelRuneSingle(pixel))
-			}
-			lines = append(lines, sb.String())
+	var frame string
+	switch {
+	case opts.graphics == graphicsSixel:
+		return letterbox(encodeSixel(img, width, height), width, height, opts.width, opts.height), nil
+	case opts.graphics == graphicsKitty:
+		frame, err := encodeKitty(img, width, height)
+		if err != nil {
+			return "", err
 		}
-	} else {
-		// For upscaling, use bilinear interpolation for smooth results
-		for y := 0; y < targetHeight; y++ {
-			var sb strings.Builder
-			for x := 0; x < targetWidth; x++ {
-				// Calculate source coordinates with floating point precision
-				srcX := float64(x) * float64(srcW) / float64(targetWidth)
-				srcY := float64(y) * float64(srcH) / float64(targetHeight)
-
-				// Get interpolated pixel value
-				pixel := bilinearInterpolate(img, srcX, srcY, srcW, srcH)
-				sb.WriteRune(pixelRuneSingle(pixel))
-			}
-			lines = append(lines, sb.String())
+		return letterbox(frame, width, height, opts.width, opts.height), nil
+	case opts.subpixel:
+		frame = strings.Join(renderSubpixel(img, width, height), "\n")
+	case opts.halfblock:
+		frame = strings.Join(renderHalfBlocks(img, width, height, opts.threshold), "\n")
+	case opts.asciiSafe:
+		frame = render.RampString(img, width, height, render.ASCIIRamp, opts.lightTerm)
+	default:
+		frame = render.BlocksString(img, width, height, opts.lightTerm)
+	}
+	// Pixels drawn by a graphics protocol have no rows to dim
+	frame = letterbox(frame, width, height, opts.width, opts.height)
+	return opts.crt.apply(frame, opts.lightTerm), nil
+}
+
+// sourceFrame returns the frameNum-th frame of the clip decoded and cropped,
+// from the source cache if it's there
+func sourceFrame(frameNum int, opts renderOptions) (*image.Gray, error) {
+	if img := sourceCache.get(frameNum); img != nil {
+		return img, nil
+	}
+	img, err := loadFrame(frameNum, opts.bg)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.crop.Empty() {
+		if !opts.crop.In(img.Bounds()) {
+			return nil, fmt.Errorf("crop %v is outside the %v frame", opts.crop, img.Bounds())
 		}
+		img = cropGray(img, opts.crop)
 	}
+	sourceCache.put(frameNum, img)
+	return img, nil
+}
 
-	return lines
+// containSize returns the largest cols x rows that fit within maxCols x
+// maxRows while keeping the source aspect ratio. Terminal cells are about
+// twice as tall as they are wide, so a frame needs more columns than rows.
+func containSize(srcW, srcH, maxCols, maxRows int) (int, int) {
+	cellAspect := float64(cellPixelHeight) / float64(cellPixelWidth)
+	aspect := float64(srcW) / float64(srcH) * cellAspect
+
+	cols := int(float64(maxRows)*aspect + 0.5)
+	if cols <= maxCols {
+		return max(1, cols), maxRows
+	}
+	rows := int(float64(maxCols)/aspect + 0.5)
+	return maxCols, max(1, min(rows, maxRows))
 }
 
-func bilinearInterpolate(img image.Image, x, y float64, maxW, maxH int) uint8 {
-	// Get the four surrounding pixels
-	x0 := int(x)
-	y0 := int(y)
-	x1 := x0 + 1
-	y1 := y0 + 1
+// letterbox centers a width x height frame within a maxWidth x maxHeight
+// area by padding it with blank rows and columns
+func letterbox(frame string, width, height, maxWidth, maxHeight int) string {
+	if width == maxWidth && height == maxHeight {
+		return frame
+	}
+
+	top := (maxHeight - height) / 2
+	bottom := maxHeight - height - top
+	left := strings.Repeat(" ", (maxWidth-width)/2)
 
-	// Clamp coordinates
-	if x1 >= maxW {
-		x1 = maxW - 1
+	lines := strings.Split(frame, "\n")
+	padded := make([]string, 0, maxHeight)
+	for i := 0; i < top; i++ {
+		padded = append(padded, "")
+	}
+	for _, line := range lines {
+		padded = append(padded, left+line)
 	}
-	if y1 >= maxH {
-		y1 = maxH - 1
+	for i := 0; i < bottom; i++ {
+		padded = append(padded, "")
 	}
+	return strings.Join(padded, "\n")
+}
 
-	// Get pixel values
-	p00 := img.At(x0, y0).(color.Gray).Y
-	p01 := img.At(x0, y1).(color.Gray).Y
-	p10 := img.At(x1, y0).(color.Gray).Y
-	p11 := img.At(x1, y1).(color.Gray).Y
+// loadGrayFrame loads a PNG frame as a grayscale image. If bg is set,
+// transparent pixels are composited over it instead of over black.
+func loadGrayFrame(filename string, bg color.Color) (*image.Gray, error) {
+	file, err := openFrameFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
-	// Calculate interpolation weights
-	fx := x - float64(x0)
-	fy := y - float64(y0)
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, err
+	}
 
-	// Bilinear interpolation
-	val := uint8(
-		float64(p00)*(1-fx)*(1-fy) +
-			float64(p10)*fx*(1-fy) +
-			float64(p01)*(1-fx)*fy +
-			float64(p11)*fx*fy,
-	)
+	if bg != nil {
+		img = compositeOver(img, bg)
+	}
 
-	return val
+	return render.GrayWeighted(img, grayWeights), nil
 }
 
-func pixelRuneSingle(pixel uint8) rune {
-	// Use more grayscale characters for better detail
+// renderHalfBlocks renders two stacked pixels per cell using '▀' with the
+// top pixel as the foreground and the bottom pixel as the background color
+func renderHalfBlocks(img *image.Gray, targetWidth, targetHeight, threshold int) []string {
+	scaled := scaleGray(img, targetWidth, targetHeight*2)
 	switch {
-	case pixel < 32:
-		return '█' // full block for very dark
-	case pixel < 64:
-		return '▓' // dark shade
-	case pixel < 96:
-		return '▒' // medium shade
-	case pixel < 128:
-		return '░' // light shade
-	case pixel < 192:
-		return ' ' // space
-	default:
-		return ' ' // space for very light
+	case threshold == thresholdAuto:
+		render.Threshold(scaled, render.Otsu(scaled))
+	case threshold >= 0:
+		render.Threshold(scaled, uint8(threshold))
 	}
+
+	lines := make([]string, 0, targetHeight)
+	for y := 0; y < targetHeight; y++ {
+		var sb strings.Builder
+		top := scaled.Pix[(2*y)*scaled.Stride:]
+		bottom := scaled.Pix[(2*y+1)*scaled.Stride:]
+		prevFg, prevBg := -1, -1
+		for x := 0; x < targetWidth; x++ {
+			fg, bg := grayToANSI256(top[x]), grayToANSI256(bottom[x])
+			// Only emit a new escape when the colors change
+			if fg != prevFg || bg != prevBg {
+				fmt.Fprintf(&sb, "\033[38;5;%d;48;5;%dm", fg, bg)
+				prevFg, prevBg = fg, bg
+			}
+			sb.WriteRune('▀')
+		}
+		sb.WriteString("\033[0m")
+		lines = append(lines, sb.String())
+	}
+	return lines
 }
 
-func (m *Model) updateSubtitle() {
-	// Calculate current video time based on frame number
-	// Video starts at frame 1, and subtitles start at ~29 seconds
-	// Each frame is ~16.67ms at 60 FPS
-	videoTime := time.Duration(m.currentFrame) * (1000 / 60) * time.Millisecond
+// renderSubpixel renders cells of eighth blocks and quadrants, each in a
+// foreground and background gray, following edges within cells
+func renderSubpixel(img *image.Gray, targetWidth, targetHeight int) []string {
+	lines := make([]string, 0, targetHeight)
+	for _, row := range render.Subpixel(img, targetWidth, targetHeight) {
+		var sb strings.Builder
+		prevFg, prevBg := -1, -1
+		for _, cell := range row {
+			fg, bg := grayToANSI256(cell.Fg), grayToANSI256(cell.Bg)
+			// Only emit a new escape when the colors change
+			if fg != prevFg || bg != prevBg {
+				fmt.Fprintf(&sb, "\033[38;5;%d;48;5;%dm", fg, bg)
+				prevFg, prevBg = fg, bg
+			}
+			sb.WriteRune(cell.Rune)
+		}
+		sb.WriteString("\033[0m")
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
 
-	// Show controls during intro
-	if videoTime < 14600*time.Millisecond {
-		m.showControls = true
-	} else {
-		m.showControls = false
+// grayToANSI256 maps a gray value to the closest color in the 256-color
+// palette, using the 24-step grayscale ramp plus pure black and white
+func grayToANSI256(v uint8) int {
+	switch {
+	case v < 4:
+		return 16 // black
+	case v > 246:
+		return 231 // white
 	}
+	// Ramp colors 232-255 cover gray levels 8 to 238 in steps of 10
+	step := (int(v) - 8 + 5) / 10
+	step = max(0, min(step, 23))
+	return 232 + step
+}
 
-	if m.subtitleMode == 0 {
-		m.currentSubtitle = ""
-		return
+// reservedRows returns how many rows around the video are needed for
+// subtitles or controls in the current state
+func (m *Model) reservedRows() int {
+	rows := m.captionRows()
+	if m.beat {
+		// Beat rules above and below the video
+		rows += 2
 	}
+	_, borderRows := m.borderSize()
+	return rows + borderRows
+}
 
-	var subs []Subtitle
-	if m.subtitleMode == 1 {
-		subs = m.subtitlesJA
-	} else {
-		subs = m.subtitlesEN
+// captionRows returns the rows needed below or above the video for
+// subtitles or controls
+func (m *Model) captionRows() int {
+	rows := 0
+	if m.subtitleMode > 0 {
+		// A blank separator, the tallest stack of cues and a trailing newline
+		rows = 2 + maxSubtitleLines(m.activeSubtitles(), m.rubyActive())
 	}
+	if m.graphics != "" {
+		// Room for the OSD, which can't be drawn over graphics
+		rows = max(rows, 1+m.osdRows())
+	}
+	return rows
+}
 
-	m.currentSubtitle = ""
-	for _, sub := range subs {
-		if videoTime >= sub.StartTime && videoTime <= sub.EndTime {
-			m.currentSubtitle = sub.Text
-			break
-		}
+// layout starts loading frames at the video size for the current terminal
+// and reserved rows, re-rendering them if that size changed
+func (m *Model) layout() tea.Cmd {
+	borderColumns, _ := m.borderSize()
+	videoWidth := max(1, m.width-borderColumns)
+	videoHeight := max(1, m.height-m.reservedRows())
+	if videoWidth == m.videoWidth && videoHeight == m.videoHeight {
+		return nil
 	}
+	m.videoWidth, m.videoHeight = videoWidth, videoHeight
+	return m.reloadFrames()
 }
 
-func initialModel(withAudio bool) Model {
-	// Load subtitles synchronously since they're embedded
-	ja, errJA := ParseSRT("bad_apple_ja.srt")
-	if errJA != nil {
-		log.Errorf("could not load japanese subtitles: %v", errJA)
+// reloadFrames stops any in-flight loading and renders all frames again at
+// the current video size. Frames from the previous size keep playing until
+// their replacements arrive.
+func (m *Model) reloadFrames() tea.Cmd {
+	if m.cancelLoading != nil {
+		m.cancelLoading()
 	}
-	en, errEN := ParseSRT("bad_apple_en.srt")
-	if errEN != nil {
-		log.Errorf("could not load english subtitles: %v", errEN)
+	var ctx context.Context
+	ctx, m.cancelLoading = context.WithCancel(m.ctx)
+	m.loadGen++
+	m.loaded = 0
+	m.frameChan = make(chan loadedFrame, cap(m.frameChan))
+	m.rendering = make(map[int]bool)
+
+	opts := m.renderOptions()
+	if m.syncLoad {
+		return loadAllFrames(ctx, opts, m.loadGen)
 	}
+	return tea.Batch(
+		loadInitialFrames(opts, m.prefetch, m.loadGen),
+		listenForFrames(ctx, m.frameChan, opts, m.prefetch),
+	)
+}
 
-	return Model{
-		frames:       make([]string, 0),
-		currentFrame: 0,
-		frameCount:   0,
-		playing:      false,
-		lastUpdate:   time.Now(),
-		width:        80, // Default width
-		height:       60, // Default height
-		loading:      false,
-		frameChan:    make(chan string, 100), // Buffer for 100 frames
-		audioStarted: false,
-		audioPlayer:  nil,
-		audioEnabled: withAudio,
-		subtitlesJA:  ja,
-		subtitlesEN:  en,
-		subtitleMode: 0,    // Default to no subtitles
-		showControls: true, // Start with controls visible
+// stopAudio closes the audio player, ending the wait for its stalls
+func (m *Model) stopAudio() {
+	if m.audioPlayer != nil {
+		m.audioPlayer.Close()
+		m.audioPlayer = nil
+	}
+	if m.audioStall != nil {
+		close(m.audioStall)
+		m.audioStall = nil
 	}
 }
 
-const (
-	defaultHost = "localhost"
-	defaultPort = "23234"
-)
-
-func getHost() string {
-	if host := os.Getenv("HOST"); host != "" {
-		return host
+// close stops any frame loading and the audio when the player quits
+func (m *Model) close() {
+	if m.cancelLoading != nil {
+		m.cancelLoading()
 	}
-	return defaultHost
+	m.stopAudio()
 }
 
-func getPort() string {
-	if port := os.Getenv("PORT"); port != "" {
-		return port
+// renderOptions returns the options to render frames at the current video size
+func (m *Model) renderOptions() renderOptions {
+	return renderOptions{
+		width:       m.videoWidth,
+		height:      m.videoHeight,
+		graphics:    m.graphics,
+		halfblock:   m.halfblock,
+		subpixel:    m.subpixel,
+		threshold:   m.threshold,
+		fit:         m.fit,
+		bg:          m.background,
+		noVideo:     m.noVideo,
+		lightTerm:   m.lightTerm,
+		crop:        m.crop,
+		fadeFrom:    m.fadeFrom,
+		crt:         m.crt,
+		asciiSafe:   m.asciiSafe,
+		interpolate: m.interpolate,
 	}
-	return defaultPort
 }
 
-// args to run in ssh mode or not, and to disable audio
-var sshMode bool
-var quietMode bool
+// resumeWhenLoaded seeks to where a returning SSH viewer left off once the
+// frames up to there have loaded, dropping positions outside the clip
+func (m *Model) resumeWhenLoaded() {
+	if m.resumeAt == 0 {
+		return
+	}
+	target := frameAt(m.resumeAt) - m.clipStart
+	if target < 0 || target >= m.clipFrames {
+		m.resumeAt = 0
+		return
+	}
+	if target < m.frameCount {
+		m.seekTo(m.resumeAt)
+		m.resumeAt = 0
+	}
+}
 
-func main() {
-	flag.BoolVar(&sshMode, "ssh", false, "run in ssh mode")
-	flag.BoolVar(&quietMode, "q", false, "disable audio")
-	flag.Parse()
+// finishLoading records that all frames are loaded at the current size
+func (m *Model) finishLoading() {
+	// Drop stale frames past the end if loading stopped early
+	m.frames = m.frames[:m.loaded]
+	m.frameCount = len(m.frames)
+	m.currentFrame = min(m.currentFrame, max(0, m.frameCount-1))
+	m.loading = false
+	m.buffering = false
+	m.totalFrames = m.frameCount
+}
 
-	// Check if frames directory exists and has frames
-	frameCount, err := countFrames()
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		fmt.Println("Please run 'go run -tags=generate .' to generate frames first")
-		os.Exit(1)
+// setPlaying starts or pauses playback along with the audio
+func (m *Model) setPlaying(playing bool) tea.Cmd {
+	if m.playing == playing || (playing && m.hold) {
+		return nil
 	}
-
-	if frameCount == 0 {
-		fmt.Println("No frames found in frames/ directory")
-		fmt.Println("Please run 'go run -tags=generate .' to generate frames first")
-		os.Exit(1)
+	m.playing = playing
+	if m.audioPlayer != nil && !m.audioMuted {
+		if m.playing {
+			if m.audioPlayer.IsPaused() {
+				m.resyncAudio()
+				m.audioPlayer.Resume()
+			} else {
+				m.audioPlayer.Play()
+			}
+		} else {
+			m.audioPlayer.Pause()
+		}
 	}
+	if m.playing {
+		return m.tick()
+	}
+	return nil
+}
 
-	if sshMode {
+// seekTo jumps the video and audio to the given time
+func (m *Model) seekTo(t time.Duration) {
+	frame := frameAt(t) - m.clipStart
+	// Only frames that have been loaded can be shown
+	frame = max(0, min(frame, m.frameCount-1))
+	m.currentFrame = frame
+	m.updateSubtitle()
+	if m.audioPlayer != nil {
+		if err := m.audioPlayer.Seek(m.audioTime()); err != nil {
+			log.Errorf("could not seek audio: %v", err)
+		}
+	}
+	if !m.loopResumeAt.IsZero() {
+		// Seeking ends a loop pause, bring back the audio it silenced
+		m.loopResumeAt = time.Time{}
+		if m.audioPlayer != nil && m.playing && !m.audioMuted {
+			m.audioPlayer.Resume()
+		}
+	}
+}
+
+// videoTime returns the position of the current frame in the full video
+func (m *Model) videoTime() time.Duration {
+	return frameTime(m.clipStart + m.currentFrame)
+}
+
+// anchorCaption pads the caption to the rows reserved for subtitles, aligned
+// to the edge of the screen, so one- and two-line cues share a baseline and
+// the frame stays put as cues come and go
+func (m Model) anchorCaption(caption string) string {
+	band := m.captionRows() - 2
+	pos := lipgloss.Bottom
+	if m.subtitleStyle.position == subtitlePositionTop {
+		pos = lipgloss.Top
+	}
+	return lipgloss.PlaceVertical(band, pos, caption)
+}
+
+// rubyActive reports whether readings are shown, only on the japanese track
+func (m Model) rubyActive() bool {
+	return m.subtitleStyle.ruby && m.subtitleMode == 1
+}
+
+// audioTime returns where in the audio playback should be now: the video
+// time, moved ahead by the audio delay so audio heard late through a
+// device's latency lines up
+func (m Model) audioTime() time.Duration {
+	return max(0, m.videoTime()+m.audioDelay)
+}
+
+// subtitleTime returns the time on the subtitle track shown now, the video
+// time less the subtitle offset
+func (m Model) subtitleTime() time.Duration {
+	return m.videoTime() - m.subOffset
+}
+
+// renderSubtitles stacks all active cues, each with its own karaoke progress
+func (m Model) renderSubtitles() string {
+	videoTime := m.subtitleTime()
+	style := m.subtitleStyle
+	style.ruby = m.rubyActive()
+	blocks := make([]string, 0, len(m.currentCues))
+	for _, cue := range m.currentCues {
+		blocks = append(blocks, style.render(m.theme, cue.Text, m.width, cue.Progress(videoTime)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, blocks...)
+}
+
+// visualizer draws the audio levels up to the current position across the
+// video area
+func (m Model) visualizer() string {
+	var levels []float64
+	if m.audioPlayer != nil {
+		levels = m.audioPlayer.Levels(m.videoTime(), m.videoWidth)
+	}
+	return renderVisualizer(levels, m.videoWidth, m.videoHeight)
+}
+
+// beatRule returns a horizontal rule that lights up on audio onsets and is
+// blank otherwise
+func (m Model) beatRule() string {
+	if m.audioPlayer == nil || !m.audioPlayer.Onset(m.videoTime()) {
+		return strings.Repeat(" ", m.videoWidth)
+	}
+	return m.theme.Highlight.Render(strings.Repeat("─", m.videoWidth))
+}
+
+// still reports whether the clip is a single frame, which never changes
+func (m *Model) still() bool {
+	return m.clipFrames == 1
+}
+
+// speed returns the playback speed at the current frame
+func (m *Model) speed() float64 {
+	if m.rampOff {
+		return 1
+	}
+	return speedAt(m.speedRamp, m.videoTime())
+}
+
+// muteForSpeed pauses the audio while the video isn't at normal speed, since
+// it isn't resampled, and resumes it in sync when normal speed returns
+func (m *Model) muteForSpeed() {
+	muted := m.speed() != 1
+	if m.audioPlayer == nil || muted == m.audioMuted {
+		return
+	}
+	m.audioMuted = muted
+	if muted {
+		m.audioPlayer.Pause()
+		return
+	}
+	if err := m.audioPlayer.Seek(m.audioTime()); err != nil {
+		log.Errorf("could not seek audio: %v", err)
+	}
+	if m.audioPlayer.IsPaused() {
+		m.audioPlayer.Resume()
+	} else {
+		m.audioPlayer.Play()
+	}
+}
+
+// startAudio opens the audio and starts it at the current frame if playing.
+// It returns a command reporting audio stalls, or nil if there is no audio.
+func (m *Model) startAudio() tea.Cmd {
+	m.audioStarted = true
+	if audioFile == "" {
+		return nil
+	}
+	audioPlayer, err := newAudio()
+	if err != nil {
+		log.Warn("could not initialize audio", "error", err)
+		return nil
+	}
+	m.audioPlayer = audioPlayer
+	m.audioPlayer.SetVolume(m.volume)
+	m.audioStall = make(chan time.Duration, 1)
+	stall := m.audioStall
+	m.audioPlayer.SetOnStall(func(pos time.Duration) {
+		// Drop the report if the last one wasn't handled yet
+		select {
+		case stall <- pos:
+		default:
+		}
+	})
+	if err := m.audioPlayer.Seek(m.audioTime()); err != nil {
+		log.Errorf("could not seek audio: %v", err)
+	}
+	if m.playing {
+		m.audioPlayer.Play()
+	}
+	m.audioMuted = false
+	m.muteForSpeed()
+	return waitForStall(m.audioStall)
+}
+
+// audioDrift is how far paused audio may be from the video before resuming
+// seeks it back into sync. Seeking drops the buffered audio, so small
+// differences are left alone rather than risk a gap.
+const audioDrift = 50 * time.Millisecond
+
+// resyncAudio seeks paused audio to the current frame if it has drifted
+// from it, such as after the video was held waiting for frames
+func (m *Model) resyncAudio() {
+	drift := m.audioPlayer.Position() - m.audioTime()
+	if drift < -audioDrift || drift > audioDrift {
+		if err := m.audioPlayer.Seek(m.audioTime()); err != nil {
+			log.Errorf("could not seek audio: %v", err)
+		}
+	}
+}
+
+// restartAudio rewinds the audio to the start of the clip, resuming it if
+// playing
+func (m *Model) restartAudio() {
+	m.loopResumeAt = time.Time{}
+	if m.audioPlayer == nil {
+		return
+	}
+	m.audioPlayer.Stop()
+	if pos := frameTime(m.clipStart) + m.audioDelay; pos > 0 {
+		if err := m.audioPlayer.Seek(pos); err != nil {
+			log.Errorf("could not seek audio: %v", err)
+		}
+	}
+	if m.playing && !m.audioMuted {
+		m.audioPlayer.Play()
+	}
+}
+
+func (m *Model) updateSubtitle() {
+	// Calculate current video time based on frame number
+	// Video starts at frame 1, and subtitles start at ~29 seconds
+	videoTime := m.subtitleTime()
+
+	if m.subtitleMode == 0 {
+		m.currentCues = nil
+		return
+	}
+
+	subs := m.activeSubtitles()
+
+	m.currentCues = nil
+	if i := findSubtitleNear(subs, videoTime, m.lastCue); i >= 0 {
+		m.lastCue = i
+		// Collect every overlapping cue that is also active
+		for ; i < len(subs) && subs[i].StartTime <= videoTime; i++ {
+			if subs[i].Active(videoTime) {
+				m.currentCues = append(m.currentCues, subs[i])
+			}
+		}
+	}
+}
+
+// activeSubtitles returns the subtitle track for the current mode
+func (m *Model) activeSubtitles() []Subtitle {
+	switch m.subtitleMode {
+	case 1:
+		return m.subtitlesJA
+	case 2:
+		return m.subtitlesEN
+	}
+	return nil
+}
+
+func initialModel(withAudio bool) Model {
+	// Load subtitles synchronously since they're embedded or small
+	ja, errJA := loadSubtitles("ja")
+	if errJA != nil {
+		log.Errorf("could not load japanese subtitles: %v", errJA)
+	}
+	en, errEN := loadSubtitles("en")
+	if errEN != nil {
+		log.Errorf("could not load english subtitles: %v", errEN)
+	}
+
+	return Model{
+		frames:        make([]string, 0),
+		currentFrame:  0,
+		frameCount:    0,
+		playing:       false,
+		lastUpdate:    time.Now(),
+		clock:         localClock,
+		width:         80, // Default width
+		height:        60, // Default height
+		loading:       false,
+		frameChan:     make(chan loadedFrame, frameBuffer),
+		ctx:           context.Background(),
+		prefetch:      prefetchFrames,
+		maxMemory:     int64(maxMemoryMB) << 20,
+		halfblock:     halfBlockMode,
+		subpixel:      subpixelMode,
+		crt:           crtEffect{intensity: crtIntensity, green: crtGreen},
+		threshold:     colorThreshold,
+		lightTerm:     termBackground == termBackgroundLight,
+		crop:          cropRect,
+		clipStart:     clipFrom - 1,
+		speedRamp:     speedRamp,
+		frameStep:     1,
+		volume:        1,
+		interpolate:   interpolateMode,
+		fit:           fitMode,
+		background:    backgroundColor,
+		audioStarted:  false,
+		audioPlayer:   nil,
+		audioEnabled:  withAudio,
+		subtitlesJA:   ja,
+		subtitlesEN:   en,
+		subtitleMode:  0, // Default to no subtitles
+		subOffset:     subtitleOffset,
+		audioDelay:    audioDelay,
+		subtitleStyle: subtitleStyle{band: subtitleBand, position: subtitlePosition, karaoke: karaokeMode, ruby: rubyMode},
+		border:        borderStyle,
+		borderTitle:   borderTitle,
+		osdVisible:    true, // Show the OSD until playback starts
+		clipFrames:    clipTo - clipFrom + 1,
+		hold:          repeatFrame > 0,
+		keys:          keyBindings,
+		theme:         newTheme(themeName, lipgloss.DefaultRenderer()),
+		once:          onceMode,
+		loopPause:     time.Duration(loopPauseMS) * time.Millisecond,
+		crossfade:     crossfade,
+		stats:         playbackStats{start: time.Now()},
+		syncLoad:      syncLoadMode,
+		noVideo:       noVideoMode,
+		beat:          beatMode,
+	}
+}
+
+const (
+	defaultHost = "localhost"
+	defaultPort = "23234"
+
+	defaultPrefetch = 30
+	defaultBuffer   = 100
+)
+
+func getHost() string {
+	if host := os.Getenv("HOST"); host != "" {
+		return host
+	}
+	return defaultHost
+}
+
+func getPort() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+	return defaultPort
+}
+
+// args to run in ssh mode or not, and to disable audio
+var sshMode bool
+
+// args to record SSH sessions, keeping at most sshRecordLimit MB of casts
+var sshRecord bool
+var sshRecordLimit = 1024
 
-		s, err := wish.NewServer(
+// arg to disconnect SSH sessions after this long without a key press
+var sshIdleTimeout = 30 * time.Minute
+
+// arg to disable audio, also -q from before -silent-output
+var noAudioMode bool
+
+// arg to print only errors, for scripts and pipes
+var silentOutput bool
+
+// args to show the start menu instead of auto-playing
+var menuMode bool
+var noMenu bool
+
+// args to tune frame loading. Every buffered or prefetched frame is a fully
+// rendered terminal-sized string, so larger values smooth playback on big
+// terminals at the cost of memory.
+var prefetchFrames = defaultPrefetch
+var frameBuffer = defaultBuffer
+
+// arg to cap the memory used by rendered frames, in MB. Frames over the
+// budget are evicted and rendered again when they come up.
+var maxMemoryMB int
+
+// arg to render real pixels with a terminal graphics protocol
+var graphicsMode string
+
+// arg to render two pixels per cell with half blocks
+var halfBlockMode bool
+
+// arg to render edges within cells with eighth blocks and quadrants
+var subpixelMode bool
+
+// args to draw ASCII frames with characters any font has, or never to, over
+// detecting it from the terminal
+var asciiSafeMode, noASCIISafe bool
+
+// arg to dim every other row like CRT scanlines, 0 to 1
+var crtIntensity float64
+
+// arg to tint text frames phosphor green
+var crtGreen bool
+
+// Special -color-threshold values
+const (
+	thresholdOff  = -1 // keep half-blocks grayscale
+	thresholdAuto = -2 // pick the cutoff for each frame with Otsu's method
+)
+
+// arg to draw half-blocks in black and white split at this gray level, parsed
+// from -color-threshold
+var colorThreshold = thresholdOff
+
+// arg to stretch frames to the terminal or keep their aspect ratio
+var fitMode = fitFill
+
+// arg to composite transparent frames over a color, parsed from -bg
+var backgroundColor color.Color
+
+// args to shift subtitles later, or earlier if negative, and to save the
+// shown track with the final shift applied on exit
+var subtitleOffset time.Duration
+var exportSubsPath string
+
+// arg to play audio ahead of the video, or behind if negative, to make up
+// for output latency
+var audioDelay time.Duration
+
+// arg to weigh color channels when frames are converted to grayscale
+var grayWeights = render.BT601
+
+// args to style the UI. subtitleColor overrides the theme's subtitle color.
+var themeName = "default"
+var subtitleColor lipgloss.Color
+var subtitleBand bool
+var subtitlePosition = subtitlePositionBottom
+
+// args to draw a border around the video
+var borderStyle = borderNone
+var borderTitle string
+
+// arg to progressively highlight subtitles as they are sung
+var karaokeMode bool
+
+// arg to show furigana over kanji in japanese subtitles
+var rubyMode bool
+
+// args to print a subtitle track and exit
+var transcriptLang string
+var transcriptPlain bool
+
+// arg to change the playback speed over time
+var speedRamp []speedPoint
+
+// arg to show only part of each frame
+var cropRect image.Rectangle
+
+// arg describing the terminal background, to shade ASCII frames to match
+var termBackground = termBackgroundAuto
+
+// arg for whether the terminal shows escape sequences, auto, on or off
+var ansiMode = ansiAuto
+
+// arg for the file descriptor the player draws to, stdout by default
+var outputFD = 1
+
+// playerOutput is where the local player draws, set by -output-fd
+var playerOutput = os.Stdout
+
+// arg to play through once and exit
+var onceMode bool
+
+// arg to hold the last frame for this many milliseconds before looping
+var loopPauseMS int
+
+// arg to blend in-between frames for sources slower than the display
+var interpolateMode bool
+
+// arg to blend playlist entries into each other over this long
+var crossfade time.Duration
+
+// arg to write playback stats as JSON on exit
+var statsOut string
+
+// args to write pprof profiles of the run
+var cpuProfile string
+var memProfile string
+
+// arg to load every frame before playback, for deterministic runs
+var syncLoadMode bool
+
+// arg to show an audio visualizer instead of the video
+var noVideoMode bool
+
+// arg to pulse rules around the video on audio onsets
+var beatMode bool
+
+// keybindings, replaced by the -keys file if given
+var keyBindings = newKeyMap(defaultBindings)
+
+func main() {
+	defer recoverPanic()
+	args, doctorMode := routeSubcommand(os.Args[1:])
+	flag.BoolVar(&sshMode, "ssh", false, "run in ssh mode")
+	flag.BoolVar(&noAudioMode, "no-audio", false, "disable audio")
+	flag.BoolVar(&noAudioMode, "q", false, "disable audio (deprecated, use -no-audio)")
+	flag.BoolVar(&silentOutput, "silent-output", false, "print only errors, no startup messages or logs below error")
+	flag.BoolVar(&menuMode, "menu", false, "show a start menu before playback")
+	flag.BoolVar(&noMenu, "no-menu", false, "skip the start menu and auto-play (default)")
+	flag.IntVar(&prefetchFrames, "prefetch", defaultPrefetch, "number of frames to load before playback starts")
+	flag.IntVar(&frameBuffer, "buffer", defaultBuffer, "number of background-loaded frames to buffer")
+	flag.IntVar(&sourceCacheMB, "source-cache", sourceCacheMB, "MB of decoded frames to keep so a resize re-renders them without reading their files again (0 to disable)")
+	flag.IntVar(&maxMemoryMB, "max-memory", 0, "MB of rendered frames to keep, evicting the farthest and re-rendering them when needed (0 for no limit)")
+	flag.StringVar(&graphicsMode, "graphics", "", "render pixels with a graphics protocol (sixel or kitty), falling back to ASCII")
+	flag.BoolVar(&halfBlockMode, "halfblock", false, "render two grayscale pixels per cell for double vertical resolution (256-color)")
+	flag.BoolVar(&asciiSafeMode, "ascii-safe", false, "draw frames with plain ASCII characters for fonts without shade blocks (default when the locale isn't UTF-8)")
+	flag.BoolVar(&noASCIISafe, "no-ascii-safe", false, "always draw frames with shade blocks, even when the locale isn't UTF-8")
+	flag.BoolVar(&subpixelMode, "subpixel", false, "render edges within cells with eighth blocks and quadrants in two grays (256-color)")
+	flag.Float64Var(&crtIntensity, "crt", 0, "dim every other row by this much (0-1) for a CRT scanline look")
+	flag.BoolVar(&crtGreen, "crt-green", false, "tint frames phosphor green (256-color)")
+	thresholdFlag := flag.String("color-threshold", "", "draw half-blocks in black and white, split at this gray level (0-255) or auto to pick it per frame")
+	subColorFlag := flag.String("sub-color", "", "subtitle color: a name (black, red, ..., white), 256-color index or #rrggbb")
+	flag.BoolVar(&subtitleBand, "sub-bg", false, "draw a dim background band behind subtitles")
+	flag.StringVar(&subtitlePosition, "sub-position", subtitlePositionBottom, "subtitle position: top or bottom")
+	subsJA := flag.String("subs-ja", "", "japanese subtitles from an .srt, .ass or .ssa file instead of the built-in track")
+	subsEN := flag.String("subs-en", "", "english subtitles from an .srt, .ass or .ssa file instead of the built-in track")
+	var subsFiles []string
+	flag.Func("subs", "subtitles from an .srt, .ass or .ssa `file`, put on the ja or en track by the language in its name (movie.ja.srt) or text (repeatable)", func(path string) error {
+		subsFiles = append(subsFiles, path)
+		return nil
+	})
+	flag.BoolVar(&karaokeMode, "karaoke", false, "progressively highlight the sung part of each subtitle")
+	flag.BoolVar(&rubyMode, "subtitle-font-hint", false, "show kana readings over kanji annotated like 漢字(かんじ) or with <ruby> tags in japanese subtitles")
+	flag.StringVar(&borderStyle, "border", borderNone, "border around the video: "+strings.Join(borderNames(), ", "))
+	flag.StringVar(&borderTitle, "border-title", "", "title set into the top edge of the -border")
+	flag.BoolVar(&integerScale, "integer-scale", false, "scale frames only by whole steps that fit the terminal, letterboxed, so pixels stay crisp instead of blended")
+	flag.StringVar(&fitMode, "fit", fitFill, "how frames fit the terminal: fill (stretch) or contain (letterbox)")
+	bgFlag := flag.String("bg", "", "composite transparent frames over this color (#rgb, #rrggbb, black or white)")
+	grayWeightsFlag := flag.String("gray-weights", "bt601", "how color frames map to gray: bt601, bt709 or an r,g,b channel mix like 1,0,0")
+	flag.StringVar(&transcriptLang, "transcript", "", "print the subtitle track for a language (ja or en) and exit")
+	flag.DurationVar(&subtitleOffset, "sub-offset", 0, "show subtitles this much later, or earlier if negative, like 1.5s or -300ms")
+	flag.Float64Var(&audioTilt, "audio-tilt", 0, "raise the treble and lower the bass by this many dB (up to 12) to brighten the sound, or warm it if negative")
+	audioDelayMS := flag.Int("audio-delay", 0, "play audio this many milliseconds ahead of the video to make up for output latency, or behind if negative")
+	flag.StringVar(&exportSubsPath, "export-subs", "", "on exit, write the subtitle track shown with its final offset to this SRT file (not in ssh mode)")
+	infoMode := flag.Bool("info", false, "print the frame count, size, frame rate, duration, audio and subtitle cue counts and exit")
+	infoJSON := flag.Bool("info-json", false, "print -info as JSON")
+	flag.StringVar(&diffDir, "diff", "", "compare the frames in this directory with those in -diff-to, report the frames that differ and exit, non-zero if any do")
+	flag.StringVar(&diffToDir, "diff-to", "", "frame directory -diff compares with")
+	flag.Float64Var(&diffThreshold, "diff-threshold", diffThreshold, "mean difference, from 0 to 255, a frame may have in -diff before it's reported")
+	flag.StringVar(&diffCSVPath, "diff-csv", "", "write every frame's -diff difference to this CSV file")
+	checkSyncMode := flag.Bool("check-sync", false, "compare the video's length with the audio's and the subtitles', suggest fixes and exit, non-zero if they are out of sync")
+	flag.BoolVar(&transcriptPlain, "transcript-plain", false, "print the transcript as plain text without timecodes")
+	keysPath := flag.String("keys", "", "keybindings file of \"action = key, key\" lines")
+	flag.StringVar(&themeName, "theme", "default", "UI theme: "+strings.Join(themeNames(), ", "))
+	flag.BoolVar(&onceMode, "once", false, "play through once and exit instead of looping")
+	flag.IntVar(&loopPauseMS, "loop-pause", 0, "milliseconds to hold the last frame in silence before looping")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the run to this file")
+	flag.StringVar(&memProfile, "memprofile", "", "write a heap profile to this file on exit")
+	flag.StringVar(&statsOut, "stats-out", "", "write playback stats as JSON to this file on exit (not in ssh mode)")
+	shotPath := flag.String("shot", "", "write the frame at -at as an ASCII-rendered PNG to this file and exit")
+	shotAt := flag.String("at", "0:00", "time of the -shot frame, as [h:]mm:ss")
+	shotWidth := flag.Int("shot-width", 80, "columns of the -shot render")
+	headlessRender := flag.String("headless-render", "", "render every frame as text at -render-width x -render-height into this directory and exit, for -prerendered")
+	renderWidth := flag.Int("render-width", 80, "columns of -headless-render frames")
+	renderHeight := flag.Int("render-height", 24, "rows of -headless-render frames")
+	flag.StringVar(&prerenderedRoot, "prerendered", "", "play frames written by -headless-render from this directory when the video size matches, instead of rendering them")
+	previewPath := flag.String("palette-preview", "", "print a frame image with each text render mode side by side and exit")
+	flag.BoolVar(&syncLoadMode, "sync-load", false, "load every frame before playback starts instead of in the background")
+	flag.BoolVar(&noVideoMode, "no-video", false, "play the audio with a level visualizer instead of the video")
+	flag.BoolVar(&beatMode, "beat", false, "pulse rules above and below the video on audio onsets")
+	playlistPath := flag.String("playlist", "", "play the videos listed in this file in turn, one frames directory with optional audio=, ja= and en= files per line")
+	flag.BoolVar(&interpolateMode, "interpolate", false, "blend in-between frames so frames slower than 60 FPS move smoothly")
+	sourceFPS := flag.Int("source-fps", 0, "frame rate of frames without timestamps.txt, like 30 for cmd/generate -fps 30 (default 60)")
+	flag.DurationVar(&crossfade, "crossfade", 0, "blend each -playlist entry into the next over this long, like 1s (0 to cut)")
+	framesURL := flag.String("frames-url", "", "download a zip or tar(.gz) of frames from this URL to the cache dir on first run and play them")
+	framesSHA := flag.String("frames-sha256", "", "SHA-256 the -frames-url archive must have")
+	packFlag := flag.String("pack", "", "play frames from a pack written by cmd/generate -pack instead of frames/")
+	framesArchive := flag.String("frames-archive", "", "play frames from a zip or uncompressed tar archive without extracting it")
+	framePatternFlag := flag.String("frame-pattern", "", "frame file names, like out%04d.png (detected from frames/ by default)")
+	flag.IntVar(&frameNaming.digits, "frame-digits", frameNaming.digits, "digits in frame file numbers, like 4 for out0001.png (0 for no padding)")
+	flag.IntVar(&frameNaming.start, "frame-start", frameNaming.start, "number of the first frame file")
+	flag.BoolVar(&sshRecord, "ssh-record", false, "record each SSH session to recordings/ as an asciinema cast")
+	flag.IntVar(&sshRecordLimit, "ssh-record-limit", sshRecordLimit, "MB of recordings to keep, deleting the oldest first")
+	flag.StringVar(&sshPrefsPath, "ssh-prefs", "", "remember each SSH viewer's subtitles, volume and position by public key in this JSON file")
+	flag.DurationVar(&sshIdleTimeout, "ssh-idle-timeout", sshIdleTimeout, "disconnect SSH sessions after this long without a key press (0 to disable)")
+	logJSON := flag.Bool("log-json", false, "write logs as JSON")
+	logLevel := flag.String("log-level", "info", "log verbosity: debug, info, warn or error")
+	flag.IntVar(&outputFD, "output-fd", outputFD, "draw the player to this open file descriptor instead of stdout, e.g. for a multiplexer")
+	flag.StringVar(&ansiMode, "ansi", ansiMode, "whether the terminal shows escape sequences: auto (enable them on Windows consoles), on, or off for plain ASCII")
+	flag.StringVar(&termBackground, "term-bg", termBackground, "terminal background, auto, dark or light, to shade ASCII frames and pick theme colors to match")
+	flag.IntVar(&clipFrom, "from", clipFrom, "first frame to play, counting from 1")
+	flag.IntVar(&clipTo, "to", clipTo, "last frame to play (default the last frame)")
+	flag.StringVar(&scriptPath, "script", "", "drive playback from a file of timed commands like: wait 2s; seek 0:30; subs en; wait 5s; quit (not in ssh or menu mode)")
+	flag.IntVar(&repeatFrame, "repeat-frame", 0, "hold this frame, counting from 1, without playing, to inspect how it renders under different settings")
+	speedRampFlag := flag.String("speed-ramp", "", "playback speed over time as seconds:speed pairs, like 0:1,60:0.25,120:1 (audio mutes when not 1x)")
+	cropFlag := flag.String("crop", "", "show only this region of each frame, as x,y,w,h in source pixels")
+	flag.Usage = usage
+	flag.CommandLine.Parse(args)
+
+	level, err := log.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if silentOutput {
+		level = max(level, log.ErrorLevel)
+	}
+	log.SetLevel(level)
+	if *logJSON {
+		log.SetFormatter(log.JSONFormatter)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "q" {
+			// -q reads like quiet output, but has always meant no audio
+			log.Warn("-q is deprecated and only disables audio, use -no-audio; -silent-output quiets output")
+		}
+	})
+
+	if sshRecordLimit <= 0 {
+		fmt.Println("Error: -ssh-record-limit must be positive")
+		os.Exit(1)
+	}
+	if prefetchFrames <= 0 || frameBuffer <= 0 {
+		fmt.Println("Error: -prefetch and -buffer must be positive")
+		os.Exit(1)
+	}
+	if maxMemoryMB < 0 {
+		fmt.Println("Error: -max-memory can't be negative")
+		os.Exit(1)
+	}
+	if sourceCacheMB < 0 {
+		fmt.Println("Error: -source-cache can't be negative")
+		os.Exit(1)
+	}
+	sourceCache.setLimit(int64(sourceCacheMB) << 20)
+	if loopPauseMS < 0 {
+		fmt.Println("Error: -loop-pause can't be negative")
+		os.Exit(1)
+	}
+	if *sourceFPS < 0 {
+		fmt.Println("Error: -source-fps can't be negative")
+		os.Exit(1)
+	}
+	if *sourceFPS > 0 {
+		frameDuration = time.Second / time.Duration(*sourceFPS)
+	}
+	if crossfade < 0 {
+		fmt.Println("Error: -crossfade can't be negative")
+		os.Exit(1)
+	}
+	if crossfade > 0 && *playlistPath == "" {
+		fmt.Println("Error: -crossfade needs a -playlist")
+		os.Exit(1)
+	}
+	if frameNaming.digits < 0 || frameNaming.start < 0 {
+		fmt.Println("Error: -frame-digits and -frame-start can't be negative")
+		os.Exit(1)
+	}
+
+	subColor, err := parseSubtitleColor(*subColorFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	subtitleColor = subColor
+	if _, ok := themes[themeName]; !ok {
+		fmt.Printf("Error: unknown theme %q (want %s)\n", themeName, strings.Join(themeNames(), ", "))
+		os.Exit(1)
+	}
+	if subtitlePosition != subtitlePositionTop && subtitlePosition != subtitlePositionBottom {
+		fmt.Printf("Error: unknown subtitle position %q (want top or bottom)\n", subtitlePosition)
+		os.Exit(1)
+	}
+
+	if *keysPath != "" {
+		keyBindings, err = loadKeyMap(*keysPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	audioDelay = time.Duration(*audioDelayMS) * time.Millisecond
+	if math.Abs(audioTilt) > maxAudioTilt {
+		fmt.Printf("Error: -audio-tilt must be between -%d and %d dB\n", maxAudioTilt, maxAudioTilt)
+		os.Exit(1)
+	}
+	if grayWeights, err = render.ParseGrayWeights(*grayWeightsFlag); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *bgFlag != "" {
+		backgroundColor, err = parseColor(*bgFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *speedRampFlag != "" {
+		speedRamp, err = parseSpeedRamp(*speedRampFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *thresholdFlag == "auto" {
+		colorThreshold = thresholdAuto
+	} else if *thresholdFlag != "" {
+		colorThreshold, err = strconv.Atoi(*thresholdFlag)
+		if err != nil || colorThreshold < 0 || colorThreshold > 255 {
+			fmt.Printf("Error: invalid color threshold %q (want 0-255 or auto)\n", *thresholdFlag)
+			os.Exit(1)
+		}
+	}
+	if crtIntensity < 0 || crtIntensity > 1 {
+		fmt.Printf("Error: -crt %v must be between 0 and 1\n", crtIntensity)
+		os.Exit(1)
+	}
+	if asciiSafeMode && (noASCIISafe || halfBlockMode || subpixelMode) {
+		fmt.Println("Error: -ascii-safe can't be used with -no-ascii-safe, -halfblock or -subpixel")
+		os.Exit(1)
+	}
+	if subpixelMode && (halfBlockMode || graphicsMode != "") {
+		fmt.Println("Error: -subpixel can't be used with -halfblock or -graphics")
+		os.Exit(1)
+	}
+	if colorThreshold != thresholdOff {
+		if !halfBlockMode && *previewPath == "" {
+			fmt.Println("Error: -color-threshold only applies to -halfblock")
+			os.Exit(1)
+		}
+	}
+
+	if *cropFlag != "" {
+		cropRect, err = parseCrop(*cropFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if termBackground != termBackgroundAuto && termBackground != termBackgroundDark && termBackground != termBackgroundLight {
+		fmt.Printf("Error: unknown terminal background %q (want auto, dark or light)\n", termBackground)
+		os.Exit(1)
+	}
+
+	if outputFD != 1 {
+		if sshMode {
+			fmt.Println("Error: -output-fd applies to local playback, not -ssh")
+			os.Exit(1)
+		}
+		out, err := openOutputFD(outputFD)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		playerOutput = out
+		// Detect colors and the background on the terminal being drawn to
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(out))
+	}
+	if sshPrefsPath != "" && !sshMode {
+		fmt.Println("Error: -ssh-prefs only applies to -ssh")
+		os.Exit(1)
+	}
+	if ansiMode != ansiAuto && ansiMode != ansiOn && ansiMode != ansiOff {
+		fmt.Printf("Error: unknown -ansi %q (want auto, on or off)\n", ansiMode)
+		os.Exit(1)
+	}
+	// SSH sessions draw on the client's terminal, not this one
+	if !sshMode {
+		if err := setupANSI(ansiMode); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if fitMode != fitFill && fitMode != fitContain {
+		fmt.Printf("Error: unknown fit mode %q (want fill or contain)\n", fitMode)
+		os.Exit(1)
+	}
+	if integerScale {
+		if fitMode != fitFill || halfBlockMode || subpixelMode {
+			fmt.Println("Error: -integer-scale can't be used with -fit, -halfblock or -subpixel")
+			os.Exit(1)
+		}
+		fitMode = fitInteger
+	}
+
+	if graphicsMode != "" && graphicsMode != graphicsSixel && graphicsMode != graphicsKitty {
+		fmt.Printf("Error: unknown graphics mode %q (want sixel or kitty)\n", graphicsMode)
+		os.Exit(1)
+	}
+
+	if _, ok := borders[borderStyle]; !ok && borderStyle != borderNone {
+		fmt.Printf("Error: unknown border %q (want %s)\n", borderStyle, strings.Join(borderNames(), ", "))
+		os.Exit(1)
+	}
+	if borderStyle != borderNone && graphicsMode != "" {
+		fmt.Println("Error: -border draws around text frames, so it can't be used with -graphics")
+		os.Exit(1)
+	}
+
+	if noVideoMode && (noAudioMode || sshMode) {
+		fmt.Println("Error: -no-video needs audio, so it can't be used with -no-audio or -ssh")
+		os.Exit(1)
+	}
+	if beatMode && (noAudioMode || sshMode) {
+		fmt.Println("Error: -beat needs audio, so it can't be used with -no-audio or -ssh")
+		os.Exit(1)
+	}
+
+	if noMenu {
+		menuMode = false
+	}
+
+	if *previewPath != "" {
+		if err := printPalettePreview(*previewPath, backgroundColor); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for lang, path := range map[string]string{"ja": *subsJA, "en": *subsEN} {
+		if path == "" {
+			continue
+		}
+		subtitleOverrides[lang] = path
+		if _, err := loadSubtitles(lang); err != nil {
+			fmt.Printf("Error: -subs-%s: %v\n", lang, err)
+			os.Exit(1)
+		}
+	}
+	for _, path := range subsFiles {
+		if err := addSubtitleFile(path); err != nil {
+			fmt.Printf("Error: -subs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if transcriptLang != "" {
+		if err := printTranscript(transcriptLang, transcriptPlain); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *framesURL != "" {
+		if *packFlag != "" || *playlistPath != "" {
+			fmt.Println("Error: -frames-url can't be used with -pack or -playlist")
+			os.Exit(1)
+		}
+		if *framesSHA != "" && !validSHA256(*framesSHA) {
+			fmt.Println("Error: -frames-sha256 must be 64 hex digits")
+			os.Exit(1)
+		}
+		frameDir, err = fetchFrames(*framesURL, strings.ToLower(*framesSHA))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *packFlag != "" {
+		framePack, err = badz.Open(*packFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *framesArchive != "" {
+		if *packFlag != "" || *framesURL != "" || *playlistPath != "" {
+			fmt.Println("Error: -frames-archive can't be used with -pack, -frames-url or -playlist")
+			os.Exit(1)
+		}
+		frameFS, err = openFrameArchive(*framesArchive)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		frameDir = "."
+	}
+
+	// Name frames from the flags if any were given, or from the frame files
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "frame-pattern", "frame-digits", "frame-start":
+			frameNamingSet = true
+		}
+	})
+	if *framePatternFlag != "" {
+		pattern, err := parseFramePattern(*framePatternFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		pattern.start = frameNaming.start
+		frameNaming = pattern
+	}
+
+	if *playlistPath != "" {
+		if sshMode || *packFlag != "" || clipFrom != 1 || clipTo != 0 || *subsJA != "" || *subsEN != "" || subsFiles != nil {
+			fmt.Println("Error: -playlist can't be used with -ssh, -pack, -from, -to, -subs, -subs-ja or -subs-en")
+			os.Exit(1)
+		}
+		playlist, err = loadPlaylist(*playlistPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Check every entry up front, ending on the first one to play it
+		for i := len(playlist) - 1; i >= 0; i-- {
+			_, err := useEntry(playlist[i])
+			if err == nil && audioFile != "" {
+				_, err = os.Stat(audioFile)
+			}
+			for _, lang := range []string{"ja", "en"} {
+				if err == nil {
+					_, err = loadSubtitles(lang)
+				}
+			}
+			if err != nil {
+				fmt.Printf("Error: playlist entry %d: %v\n", i+1, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if diffDir != "" || diffToDir != "" {
+		if diffDir == "" || diffToDir == "" {
+			fmt.Println("Error: -diff and -diff-to need each other")
+			os.Exit(1)
+		}
+		if framePack != nil || frameFS != nil {
+			fmt.Println("Error: -diff compares frame directories, not -pack or -frames-archive")
+			os.Exit(1)
+		}
+		if diffThreshold < 0 || diffThreshold > 255 {
+			fmt.Printf("Error: -diff-threshold %v must be between 0 and 255\n", diffThreshold)
+			os.Exit(1)
+		}
+		ok, err := diffFrameSets(diffDir, diffToDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if doctorMode {
+		if !doctor() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check if frames directory exists and has frames
+	frameCount, err := openFrames()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Please run 'go run . generate' to generate frames first")
+		os.Exit(1)
+	}
+
+	if frameCount == 0 {
+		fmt.Printf("No frames found in %s/ directory\n", frameDir)
+		fmt.Println("Please run 'go run . generate' to generate frames first")
+		os.Exit(1)
+	}
+
+	if *infoMode || *infoJSON {
+		info, err := collectInfo(frameCount)
+		if err == nil {
+			err = printInfo(info, *infoJSON)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *checkSyncMode {
+		ok, err := checkSync(frameCount)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var script []scriptStep
+	if scriptPath != "" {
+		if sshMode || menuMode {
+			fmt.Println("Error: -script can't be used with -ssh or -menu")
+			os.Exit(1)
+		}
+		script, err = loadScript(scriptPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if repeatFrame != 0 {
+		if clipFrom != 1 || clipTo != 0 || onceMode || *playlistPath != "" || sshMode {
+			fmt.Println("Error: -repeat-frame can't be used with -from, -to, -once, -playlist or -ssh")
+			os.Exit(1)
+		}
+		if repeatFrame < 1 || repeatFrame > frameCount {
+			fmt.Printf("Error: -repeat-frame must be between 1 and %d\n", frameCount)
+			os.Exit(1)
+		}
+		// A clip of the one frame, so only it is rendered, and silence
+		clipFrom, clipTo = repeatFrame, repeatFrame
+		noAudioMode = true
+	}
+	if clipTo == 0 {
+		clipTo = frameCount
+	}
+	if clipFrom < 1 || clipFrom > clipTo || clipTo > frameCount {
+		fmt.Printf("Error: -from and -to must satisfy 1 <= from <= to <= %d\n", frameCount)
+		os.Exit(1)
+	}
+
+	// Check the crop against the frame size before any frames are rendered
+	if !cropRect.Empty() {
+		first, err := loadFrame(1, nil)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if b := first.Bounds(); !cropRect.In(b) {
+			fmt.Printf("Error: crop %v is outside the %dx%d frames\n", cropRect, b.Dx(), b.Dy())
+			os.Exit(1)
+		}
+	}
+
+	if *headlessRender != "" {
+		if *renderWidth < 1 || *renderHeight < 1 {
+			fmt.Println("Error: -render-width and -render-height must be at least 1")
+			os.Exit(1)
+		}
+		if graphicsMode != "" || *playlistPath != "" {
+			fmt.Println("Error: -headless-render renders text, so it can't be used with -graphics or -playlist")
+			os.Exit(1)
+		}
+		// Every frame is read once, caching them would only use memory
+		sourceCache.setLimit(0)
+		opts := renderOptions{
+			width:     *renderWidth,
+			height:    *renderHeight,
+			halfblock: halfBlockMode,
+			subpixel:  subpixelMode,
+			threshold: colorThreshold,
+			fit:       fitMode,
+			bg:        backgroundColor,
+			lightTerm: termBackground == termBackgroundLight,
+			crop:      cropRect,
+			asciiSafe: asciiSafeMode,
+		}
+		if err := prerender(*headlessRender, opts, clipTo-clipFrom+1); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if prerenderedRoot != "" && *playlistPath != "" {
+		fmt.Println("Error: -prerendered can't be used with -playlist")
+		os.Exit(1)
+	}
+
+	if *shotPath != "" {
+		at, err := parseClock(*shotAt)
+		if err == nil && *shotWidth < 1 {
+			err = fmt.Errorf("-shot-width must be at least 1")
+		}
+		if err == nil {
+			err = writeShot(*shotPath, at, *shotWidth, frameCount)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	stopProfiles, err := startProfiles(cpuProfile, memProfile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if sshMode {
+		var auth []ssh.Option
+		if sshPrefsPath != "" {
+			viewerStore, err = loadPrefsStore(sshPrefsPath)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			// Ask for a key to recognize viewers by, still letting in those
+			// without one
+			auth = append(auth,
+				wish.WithPublicKeyAuth(func(ssh.Context, ssh.PublicKey) bool { return true }),
+				wish.WithKeyboardInteractiveAuth(func(ssh.Context, gossh.KeyboardInteractiveChallenge) bool { return true }),
+			)
+		}
+
+		// Middleware runs last to first, so recording wraps the session
+		// before Bubble Tea writes to it
+		middleware := []wish.Middleware{bubbletea.Middleware(teaHandler)}
+		if sshRecord {
+			middleware = append(middleware, recordMiddleware(int64(sshRecordLimit)<<20))
+		}
+		middleware = append(middleware,
+			activeterm.Middleware(), // Bubble Tea apps usually require a PTY.
+			// Raw connection events, sessions are logged by teaHandler
+			logging.StructuredMiddlewareWithLogger(log.Default(), log.DebugLevel),
+		)
+
+		s, err := wish.NewServer(append([]ssh.Option{
 			wish.WithAddress(net.JoinHostPort(getHost(), getPort())),
 			wish.WithHostKeyPath(".ssh/id_ed25519"),
-			wish.WithMiddleware(
-				bubbletea.Middleware(teaHandler),
-				activeterm.Middleware(), // Bubble Tea apps usually require a PTY.
-				logging.Middleware(),
-			),
-		)
+			wish.WithMiddleware(middleware...),
+		}, auth...)...)
 		if err != nil {
 			log.Error("Could not start server", "error", err)
 		}
@@ -569,13 +2202,49 @@ func main() {
 		if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
 			log.Error("Could not stop server", "error", err)
 		}
+		stopProfiles()
 	} else {
-		p := tea.NewProgram(initialModel(!sshMode && !quietMode), tea.WithAltScreen())
-		if _, err := p.Run(); err != nil {
+		localClock = newFrameClock()
+		p := tea.NewProgram(startModel(!sshMode && !noAudioMode), tea.WithAltScreen(), tea.WithoutSignalHandler(), tea.WithOutput(playerOutput))
+		crashProgram = p
+		clockCtx, stopClock := context.WithCancel(context.Background())
+		localClock.start(clockCtx, p.Send)
+		go runScript(clockCtx, script, p.Send)
+
+		// Quit through Bubble Tea on SIGINT/SIGTERM so the terminal is restored
+		done := make(chan os.Signal, 1)
+		signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-done
+			p.Quit()
+		}()
+
+		final, err := p.Run()
+		signal.Stop(done)
+		stopClock()
+		if m, ok := final.(Model); ok {
+			m.close()
+			if statsOut != "" {
+				if err := writeStats(statsOut, m.stats); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+			}
+			if exportSubsPath != "" {
+				if m.subtitleMode == 0 {
+					fmt.Println("Error: subtitles were off on exit, nothing to write to -export-subs")
+				} else if err := exportSubtitles(exportSubsPath, m.activeSubtitles(), m.subOffset); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+			}
+		}
+		// After a panic Bubble Tea returns the initial model, so close any
+		// audio the real one left playing
+		closeAudio()
+		stopProfiles()
+		if err != nil {
 			fmt.Printf("Error running program: %v", err)
 			os.Exit(1)
 		}
-
 	}
 }
 
@@ -585,10 +2254,97 @@ func main() {
 // tea.WithAltScreen) on a session by session basis.
 func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	// Enable audio in SSH mode unless quiet mode is set
-	audioEnabled := !quietMode
-	m := initialModel(audioEnabled)
+	audioEnabled := !noAudioMode
 	pty, _, _ := s.Pty()
-	m.width, m.height = pty.Window.Width, pty.Window.Height
+	start := time.Now()
+	log.Info("session started", "remote", s.RemoteAddr().String(), "user", s.User(),
+		"term", pty.Term, "width", pty.Window.Width, "height", pty.Window.Height)
+	go func() {
+		<-s.Context().Done()
+		log.Info("session ended", "remote", s.RemoteAddr().String(), "duration", time.Since(start).Round(time.Second))
+	}()
+
+	// Detect graphics support from the client's terminal, not the server's
+	graphics := detectGraphics(graphicsMode, pty.Term, s.Environ())
+	asciiSafe := asciiSafeFor(pty.Term, s.Environ())
+	// Style with the client's color profile and background
+	renderer := bubbletea.MakeRenderer(s)
+	lightTerm := lightTerminal(renderer)
+	theme := newTheme(themeName, renderer)
+	var model tea.Model
+	if menuMode {
+		m := initialMenu(audioEnabled)
+		m.width, m.height = pty.Window.Width, pty.Window.Height
+		m.ctx = s.Context()
+		m.graphics = graphics
+		m.theme = theme
+		m.lightTerm = lightTerm
+		m.asciiSafe = asciiSafe
+		model = m
+	} else {
+		m := initialModel(audioEnabled)
+		m.width, m.height = pty.Window.Width, pty.Window.Height
+		// Stop loading frames when the client disconnects
+		m.ctx = s.Context()
+		m.graphics = graphics
+		m.theme = theme
+		m.lightTerm = lightTerm
+		m.asciiSafe = asciiSafe
+		model = m
+	}
+	model = withViewerPrefs(s, model)
+
+	if sshIdleTimeout > 0 {
+		idle := newIdleModel(model, sshIdleTimeout)
+		idle.width, idle.height = pty.Window.Width, pty.Window.Height
+		model = idle
+	}
+	return model, []tea.ProgramOption{tea.WithAltScreen()}
+}
+
+// startModel returns the start menu or the player depending on --menu
+func startModel(withAudio bool) tea.Model {
+	graphics := detectGraphics(graphicsMode, os.Getenv("TERM"), os.Environ())
+	lightTerm := lightTerminal(lipgloss.DefaultRenderer())
+	asciiSafe := asciiSafeFor(os.Getenv("TERM"), os.Environ())
+	if menuMode {
+		m := initialMenu(withAudio)
+		m.graphics = graphics
+		m.lightTerm = lightTerm
+		m.plain = plainOutput
+		m.asciiSafe = asciiSafe
+		return m
+	}
+	m := initialModel(withAudio)
+	m.graphics = graphics
+	m.lightTerm = lightTerm
+	m.plain = plainOutput
+	m.asciiSafe = asciiSafe
+	return m
+}
 
-	return m, []tea.ProgramOption{tea.WithAltScreen()}
+// openOutputFD opens file descriptor fd for -output-fd, checking that it is
+// open for writing
+func openOutputFD(fd int) (*os.File, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("-output-fd %d can't be negative", fd)
+	}
+	out := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	// An empty write fails on descriptors that are closed or read-only
+	if _, err := out.Write(nil); err != nil {
+		return nil, fmt.Errorf("-output-fd %d isn't open for writing", fd)
+	}
+	return out, nil
+}
+
+// lightTerminal reports whether the terminal behind r has a light
+// background. With -term-bg auto the terminal is asked with an OSC 11 query,
+// falling back to dark if it doesn't answer. Otherwise the flag is applied to
+// r so adaptive theme colors follow it. Call it before Bubble Tea starts
+// reading input, which would swallow the terminal's reply.
+func lightTerminal(r *lipgloss.Renderer) bool {
+	if termBackground != termBackgroundAuto {
+		r.SetHasDarkBackground(termBackground == termBackgroundDark)
+	}
+	return !r.HasDarkBackground()
 }
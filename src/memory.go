@@ -0,0 +1,99 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// frameMemorySamples is how many frames are measured to estimate the size
+// of all of them
+const frameMemorySamples = 64
+
+// frameRenderedMsg carries a frame re-rendered after it was evicted
+type frameRenderedMsg struct {
+	index int
+	frame string
+	gen   int
+}
+
+// frameMemory estimates the memory held by frames. Frames rendered at the
+// same size have similar lengths, so the average size of a sample of them is
+// multiplied by the number of frames that aren't evicted.
+func frameMemory(frames []string) int64 {
+	resident, sampled, size := 0, 0, 0
+	step := max(1, len(frames)/frameMemorySamples)
+	for i, frame := range frames {
+		if frame == "" {
+			continue
+		}
+		resident++
+		if i%step == 0 {
+			sampled++
+			size += len(frame)
+		}
+	}
+	if sampled == 0 {
+		return 0
+	}
+	return int64(size/sampled) * int64(resident)
+}
+
+// evicted reports whether frame i was dropped to stay under -max-memory
+func (m *Model) evicted(i int) bool {
+	return m.maxMemory > 0 && !m.noVideo && i < len(m.frames) && m.frames[i] == ""
+}
+
+// evictFrames drops the frames farthest ahead of the current one in playback
+// order until the estimated frame memory is within -max-memory. The frames
+// just behind the current one go first, since they won't be shown again
+// until the video loops.
+func (m *Model) evictFrames() {
+	if m.maxMemory <= 0 || m.frameCount == 0 {
+		return
+	}
+	usage := frameMemory(m.frames)
+	if usage <= m.maxMemory {
+		return
+	}
+
+	evicted := 0
+	for d := 1; d < m.frameCount && usage > m.maxMemory; d++ {
+		i := (m.currentFrame - d + m.frameCount) % m.frameCount
+		if m.frames[i] == "" {
+			continue
+		}
+		usage -= int64(len(m.frames[i]))
+		m.frames[i] = ""
+		evicted++
+	}
+	log.Debug("evicted frames", "count", evicted, "estimate", usage, "budget", m.maxMemory)
+}
+
+// refill re-renders evicted frames from the current one up to the prefetch
+// count ahead, so they're back before they're shown
+func (m *Model) refill() tea.Cmd {
+	if m.maxMemory <= 0 || m.frameCount == 0 {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for d := 0; d < min(m.prefetch, m.frameCount); d++ {
+		i := (m.currentFrame + d) % m.frameCount
+		if !m.evicted(i) || m.rendering[i] {
+			continue
+		}
+		m.rendering[i] = true
+		cmds = append(cmds, renderFrameAt(i, m.renderOptions(), m.loadGen))
+	}
+	return tea.Batch(cmds...)
+}
+
+// renderFrameAt renders the frame at index i of the clip
+func renderFrameAt(i int, opts renderOptions, gen int) tea.Cmd {
+	return func() tea.Msg {
+		frame, _, err := renderFrame(i+1, opts)
+		if err != nil {
+			log.Errorf("could not render frame %d: %v", i+1, err)
+		}
+		return frameRenderedMsg{index: i, frame: frame, gen: gen}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Menu entries shown on the start screen
+const (
+	menuPlay = iota
+	menuPlayWithSubtitles
+	menuToggleAudio
+	menuSettings
+	menuQuit
+)
+
+// MenuModel is the start screen shown before playback when --menu is set
+type MenuModel struct {
+	cursor       int
+	width        int
+	height       int
+	audioEnabled bool
+	subtitleMode int // 0: off, 1: JA, 2: EN
+	halfblock    bool
+	subpixel     bool
+	volume       float64
+	frameStep    int
+	settings     settingsPanel
+	graphics     string
+	lightTerm    bool
+	plain        bool // draw without escape sequences, passed on to the player
+	asciiSafe    bool
+	theme        Theme
+	ctx          context.Context // passed on to the player
+}
+
+// initialMenu creates the start screen with the given defaults
+func initialMenu(withAudio bool) MenuModel {
+	return MenuModel{
+		width:        80,
+		height:       60,
+		audioEnabled: withAudio,
+		subtitleMode: 1, // Default language for "Play with subtitles"
+		halfblock:    halfBlockMode,
+		subpixel:     subpixelMode,
+		volume:       1,
+		frameStep:    1,
+		theme:        newTheme(themeName, lipgloss.DefaultRenderer()),
+		ctx:          context.Background(),
+	}
+}
+
+// Init initializes the menu
+func (m MenuModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles menu navigation and transitions into the player
+func (m MenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+	case settingChangedMsg:
+		m.applySetting(msg)
+		return m, nil
+	case tea.KeyMsg:
+		if m.settings.active && msg.String() != "ctrl+c" {
+			var cmd tea.Cmd
+			m.settings, cmd = m.settings.Update(msg)
+			return m, cmd
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.items())-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			switch m.cursor {
+			case menuPlay:
+				return m.startPlayer(0)
+			case menuPlayWithSubtitles:
+				return m.startPlayer(m.subtitleMode)
+			case menuToggleAudio:
+				m.audioEnabled = !m.audioEnabled
+			case menuSettings:
+				m.openSettings()
+			case menuQuit:
+				return m, tea.Quit
+			}
+		}
+	}
+	return m, nil
+}
+
+// openSettings opens the settings panel with the options the player starts
+// with. Audio is toggled from the menu itself.
+func (m *MenuModel) openSettings() {
+	settings := []setting{{
+		id: settingSubtitles, label: "Subtitle language",
+		values: []string{subtitleLanguageName(1), subtitleLanguageName(2)},
+		index:  m.subtitleMode - 1,
+	}}
+	if m.graphics == "" {
+		settings = append(settings, setting{
+			id: settingColor, label: "Color mode", values: colorModes,
+			index: colorModeIndex(m.halfblock, m.subpixel),
+		})
+	}
+	if !sshMode {
+		settings = append(settings, setting{
+			id: settingVolume, label: "Volume", values: volumeChoices,
+			index: int(m.volume*10 + 0.5),
+		})
+	}
+	settings = append(settings, setting{
+		id: settingFPS, label: "FPS", values: fpsChoices,
+		index: m.frameStep - 1,
+	})
+	m.settings = newSettingsPanel(settings, m.theme)
+}
+
+// applySetting records a change from the settings panel for the player
+func (m *MenuModel) applySetting(msg settingChangedMsg) {
+	switch msg.id {
+	case settingSubtitles:
+		m.subtitleMode = msg.index + 1
+	case settingColor:
+		m.halfblock = msg.index == 1
+		m.subpixel = msg.index == 2
+	case settingVolume:
+		m.volume = float64(msg.index) / 10
+	case settingFPS:
+		m.frameStep = msg.index + 1
+	}
+}
+
+// startPlayer hands off to the player model with the chosen options
+func (m MenuModel) startPlayer(subtitleMode int) (tea.Model, tea.Cmd) {
+	player := initialModel(m.audioEnabled)
+	player.subtitleMode = subtitleMode
+	player.halfblock = m.halfblock
+	player.subpixel = m.subpixel
+	player.volume = m.volume
+	player.frameStep = m.frameStep
+	player.graphics = m.graphics
+	player.lightTerm = m.lightTerm
+	player.plain = m.plain
+	player.asciiSafe = m.asciiSafe
+	if m.plain {
+		// Half blocks and subpixels are drawn in color
+		player.halfblock, player.subpixel = false, false
+	}
+	player.theme = m.theme
+	player.ctx = m.ctx
+	// Replay the known terminal size so the player starts loading frames
+	return player.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+}
+
+// items returns the menu labels, reflecting the current option state
+func (m MenuModel) items() []string {
+	audio := "off"
+	if m.audioEnabled {
+		audio = "on"
+	}
+	return []string{
+		"Play",
+		"Play with subtitles",
+		fmt.Sprintf("Toggle audio (%s)", audio),
+		"Settings",
+		"Quit",
+	}
+}
+
+// View renders the menu
+func (m MenuModel) View() string {
+	var lines []string
+	if m.settings.active {
+		// Split so each line is padded on its own and the panel stays aligned
+		lines = append(lines, strings.Split(m.settings.View(), "\n")...)
+	} else {
+		lines = append(lines, "senshukai", "")
+		for i, item := range m.items() {
+			if i == m.cursor {
+				lines = append(lines, "> "+item)
+			} else {
+				lines = append(lines, "  "+item)
+			}
+		}
+		lines = append(lines, "", m.theme.Controls.Render("[↑/↓] move | [enter] select | [q] quit"))
+	}
+
+	view := lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Left, lines...))
+	if m.plain {
+		view = ansi.Strip(view)
+	}
+	return view
+}
+
+// subtitleLanguageName returns a display name for a subtitle mode
+func subtitleLanguageName(mode int) string {
+	switch mode {
+	case 1:
+		if label := subtitleLabels["ja"]; label != "" {
+			return label
+		}
+		return "Japanese"
+	case 2:
+		if label := subtitleLabels["en"]; label != "" {
+			return label
+		}
+		return "English"
+	default:
+		return "Off"
+	}
+}
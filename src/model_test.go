@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/exp/teatest"
+)
+
+// testShades are the grays of the test frames, each drawn with a different
+// block: █, ▓, ▒ and ░
+var testShades = []uint8{0, 40, 80, 112}
+
+// useTestFrames writes a frame of each of testShades to a temporary frames
+// directory and points the player at it with -sync-load, restoring the
+// globals after the test
+func useTestFrames(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for i, shade := range testShades {
+		img := image.NewGray(image.Rect(0, 0, 8, 6))
+		for p := range img.Pix {
+			img.Pix[p] = shade
+		}
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("out%04d.png", i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(file, img); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+	}
+
+	oldDir, oldTo, oldSync := frameDir, clipTo, syncLoadMode
+	t.Cleanup(func() {
+		frameDir, clipTo, syncLoadMode = oldDir, oldTo, oldSync
+		sourceCache.reset()
+	})
+	frameDir, clipTo, syncLoadMode = dir, len(testShades), true
+	sourceCache.reset()
+}
+
+// testBlock returns the block frame i of the test frames is drawn with
+func testBlock(i int) string {
+	return string([]rune("█▓▒░")[i])
+}
+
+// key returns the message for pressing a key
+func key(s string) tea.KeyMsg {
+	if s == " " {
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(s)}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// update passes msg to the model, failing the test if it isn't a Model after
+func update(t *testing.T, m Model, msg tea.Msg) (Model, tea.Cmd) {
+	t.Helper()
+	model, cmd := m.Update(msg)
+	next, ok := model.(Model)
+	if !ok {
+		t.Fatalf("Update(%T) returned a %T", msg, model)
+	}
+	return next, cmd
+}
+
+func TestModelPlayback(t *testing.T) {
+	useTestFrames(t)
+	m := initialModel(false)
+	if !strings.Contains(m.View(), "Loading frames") {
+		t.Errorf("View before loading = %q, want the loading message", m.View())
+	}
+
+	// The first size starts loading, which -sync-load does in one message
+	m, cmd := update(t, m, tea.WindowSizeMsg{Width: 20, Height: 10})
+	if cmd == nil {
+		t.Fatal("WindowSizeMsg started no loading")
+	}
+	loaded, ok := cmd().(framesLoadedMsg)
+	if !ok || !loaded.complete || len(loaded.frames) != len(testShades) {
+		t.Fatalf("loading sent %#v, want every frame in one framesLoadedMsg", loaded)
+	}
+	m, _ = update(t, m, loaded)
+	if !m.playing || m.frameCount != len(testShades) || m.currentFrame != 0 {
+		t.Fatalf("after loading: playing %v, %d frames, frame %d, want playing from frame 0 of %d",
+			m.playing, m.frameCount, m.currentFrame, len(testShades))
+	}
+
+	// Each tick shows the next frame, looping back after the last
+	for i := 0; i <= len(testShades); i++ {
+		want := i % len(testShades)
+		if m.currentFrame != want {
+			t.Fatalf("after %d ticks frame = %d, want %d", i, m.currentFrame, want)
+		}
+		if view := m.View(); !strings.Contains(view, testBlock(want)) {
+			t.Errorf("frame %d view = %q, want it drawn with %q", want, view, testBlock(want))
+		}
+		m, _ = update(t, m, tickMsg(time.Now()))
+	}
+
+	// Space pauses, and ticks don't move a paused video
+	m, _ = update(t, m, key(" "))
+	if m.playing {
+		t.Error("space didn't pause")
+	}
+	paused := m.currentFrame
+	m, _ = update(t, m, tickMsg(time.Now()))
+	if m.currentFrame != paused {
+		t.Errorf("tick while paused moved from frame %d to %d", paused, m.currentFrame)
+	}
+	m, _ = update(t, m, key(" "))
+	if !m.playing {
+		t.Error("space didn't resume")
+	}
+
+	// s cycles the subtitles off, Japanese, English and off again
+	for _, want := range []int{1, 2, 0} {
+		m, _ = update(t, m, key("s"))
+		if m.subtitleMode != want {
+			t.Errorf("subtitle mode = %d, want %d", m.subtitleMode, want)
+		}
+	}
+
+	// r goes back to the first frame
+	m, _ = update(t, m, key("r"))
+	if m.currentFrame != 0 {
+		t.Errorf("after reset frame = %d, want 0", m.currentFrame)
+	}
+
+	_, cmd = update(t, m, key("q"))
+	if !quits(cmd) {
+		t.Error("q didn't quit")
+	}
+}
+
+// quits reports whether cmd, or any command it batches, quits the program
+func quits(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	switch msg := cmd().(type) {
+	case tea.QuitMsg:
+		return true
+	case tea.BatchMsg:
+		for _, c := range msg {
+			if quits(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestModelProgram(t *testing.T) {
+	useTestFrames(t)
+	tm := teatest.NewTestModel(t, initialModel(false), teatest.WithInitialTermSize(20, 10))
+
+	// Playback starts and loops through every frame on its own
+	waitFor := func(block string) {
+		t.Helper()
+		teatest.WaitFor(t, tm.Output(), func(out []byte) bool {
+			return bytes.Contains(out, []byte(block))
+		}, teatest.WithDuration(5*time.Second), teatest.WithCheckInterval(10*time.Millisecond))
+	}
+	for i := range testShades {
+		waitFor(testBlock(i))
+	}
+
+	tm.Send(key("s"))
+	tm.Send(key("s"))
+	tm.Send(key(" "))
+	tm.Send(key("q"))
+	final, ok := tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Model)
+	if !ok {
+		t.Fatal("final model isn't a Model")
+	}
+	if final.subtitleMode != 2 {
+		t.Errorf("subtitle mode = %d, want 2 (English) after pressing s twice", final.subtitleMode)
+	}
+	if final.playing {
+		t.Error("video still playing after space")
+	}
+}
+
+// startPlayback sizes the model and loads the test frames, which starts it
+// playing
+func startPlayback(t *testing.T, m Model) Model {
+	t.Helper()
+	m, cmd := update(t, m, tea.WindowSizeMsg{Width: 20, Height: 10})
+	m, _ = update(t, m, cmd())
+	if !m.playing {
+		t.Fatal("loading the frames didn't start playback")
+	}
+	return m
+}
+
+// wantCalls fails the test unless the fake audio was called with want since
+// the last check
+func wantCalls(t *testing.T, fa *fakeAudio, when string, want ...string) {
+	t.Helper()
+	if got := fa.takeCalls(); !slices.Equal(got, want) {
+		t.Errorf("%s: audio calls = %q, want %q", when, got, want)
+	}
+}
+
+func TestModelAudio(t *testing.T) {
+	useTestFrames(t)
+	fa := useFakeAudio(t)
+	m := startPlayback(t, initialModel(true))
+	if m.audioPlayer != fa {
+		t.Fatalf("audio player = %v, want the fake", m.audioPlayer)
+	}
+	wantCalls(t, fa, "starting", fmt.Sprintf("seek %v", m.audioTime()), "play")
+
+	m, _ = update(t, m, tickMsg(time.Now()))
+	m, _ = update(t, m, key(" "))
+	wantCalls(t, fa, "pausing", "pause")
+
+	// Audio still where the video is resumes without a seek
+	fa.pos = m.audioTime()
+	m, _ = update(t, m, key(" "))
+	wantCalls(t, fa, "resuming", "resume")
+
+	// Seeking moves the audio with the video, here clamped to the last frame
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	if m.currentFrame != len(testShades)-1 {
+		t.Errorf("after seeking frame = %d, want the last, %d", m.currentFrame, len(testShades)-1)
+	}
+	wantCalls(t, fa, "seeking", fmt.Sprintf("seek %v", m.audioTime()))
+
+	// Reset rewinds the audio and plays it again from the start
+	m, _ = update(t, m, key("r"))
+	wantCalls(t, fa, "resetting", "stop", "play")
+
+	m, _ = update(t, m, key("q"))
+	if !fa.closed {
+		t.Error("quitting didn't close the audio")
+	}
+}
+
+func TestModelPauseContinuity(t *testing.T) {
+	useTestFrames(t)
+	fa := useFakeAudio(t)
+	m := startPlayback(t, initialModel(true))
+	m, _ = update(t, m, key(" "))
+	// The last frame leaves room for the audio to be behind it
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	fa.takeCalls()
+
+	// Neither the video nor the audio moves while paused
+	video := m.videoTime()
+	for range 3 {
+		m, _ = update(t, m, tickMsg(time.Now()))
+	}
+	if m.videoTime() != video {
+		t.Errorf("video moved from %v to %v while paused", video, m.videoTime())
+	}
+
+	for _, tt := range []struct {
+		name  string
+		drift time.Duration
+		seeks bool
+	}{
+		{"in sync", 0, false},
+		{"within the drift allowed", audioDrift - time.Millisecond, false},
+		{"ahead", audioDrift + time.Millisecond, true},
+		{"behind", -audioDrift - time.Millisecond, true},
+	} {
+		// Audio left at its own position while the video was held
+		fa.pos = m.audioTime() + tt.drift
+		before := fa.pos
+		m, _ = update(t, m, key(" "))
+		if m.videoTime() != video {
+			t.Errorf("%s: resuming moved the video from %v to %v", tt.name, video, m.videoTime())
+		}
+		if tt.seeks {
+			wantCalls(t, fa, tt.name, fmt.Sprintf("seek %v", m.audioTime()), "resume")
+			if fa.Position() != m.audioTime() {
+				t.Errorf("%s: audio resumed at %v, want %v with the video", tt.name, fa.Position(), m.audioTime())
+			}
+		} else {
+			wantCalls(t, fa, tt.name, "resume")
+			if fa.Position() != before {
+				t.Errorf("%s: audio jumped from %v to %v", tt.name, before, fa.Position())
+			}
+		}
+		m, _ = update(t, m, key(" "))
+		fa.takeCalls()
+	}
+}
+
+func TestModelAudioStallWait(t *testing.T) {
+	useTestFrames(t)
+	fa := useFakeAudio(t)
+	m := startPlayback(t, initialModel(true))
+
+	// A stall reported by the audio brings the video back to it
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	wait := waitForStall(m.audioStall)
+	fa.onStall(0)
+	m, next := update(t, m, wait())
+	if m.currentFrame != 0 || m.stats.audioStalls != 1 {
+		t.Errorf("after a stall at 0: frame %d, %d stalls, want frame 0 and 1 stall", m.currentFrame, m.stats.audioStalls)
+	}
+
+	// Turning audio off ends the wait for the next stall
+	done := make(chan tea.Msg)
+	go func() { done <- next() }()
+	m.applySetting(settingChangedMsg{id: settingAudio, index: 0})
+	select {
+	case msg := <-done:
+		if msg != nil {
+			t.Errorf("wait for a stall after audio was turned off sent %#v, want nil", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting for a stall didn't end when audio was turned off")
+	}
+	if !fa.closed {
+		t.Error("turning audio off didn't close it")
+	}
+
+	// A stall from audio that was turned off is ignored once audio is back
+	stale := audioStallMsg{pos: 0, stall: make(chan time.Duration)}
+	m.applySetting(settingChangedMsg{id: settingAudio, index: 1})
+	m, _ = update(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	m, cmd := update(t, m, stale)
+	if m.currentFrame == 0 || cmd != nil {
+		t.Errorf("stale stall moved to frame %d, returned a command %v, want it ignored", m.currentFrame, cmd != nil)
+	}
+}
+
+func TestSearchQuitClosesPlayer(t *testing.T) {
+	useTestFrames(t)
+	fa := useFakeAudio(t)
+	m := startPlayback(t, initialModel(true))
+	cancelled := false
+	m.cancelLoading = func() { cancelled = true }
+
+	m, _ = update(t, m, key("/"))
+	if !m.search.active {
+		t.Fatal("/ didn't open the search box")
+	}
+	_, cmd := update(t, m, tea.KeyMsg{Type: tea.KeyCtrlC})
+	if !quits(cmd) {
+		t.Error("ctrl+c in the search box didn't quit")
+	}
+	if !cancelled || !fa.closed {
+		t.Errorf("ctrl+c in the search box: loading cancelled %v, audio closed %v, want both", cancelled, fa.closed)
+	}
+}
+
+func TestLoadRemainingFramesCancel(t *testing.T) {
+	useTestFrames(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frameChan := make(chan loadedFrame)
+	done := make(chan struct{})
+	go func() {
+		loadRemainingFrames(ctx, frameChan, renderOptions{width: 8, height: 3}, 0)
+		close(done)
+	}()
+
+	// Take one frame, then cancel while the loader waits to send the next
+	if _, ok := <-frameChan; !ok {
+		t.Fatal("loader closed the frame channel before sending a frame")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loader didn't stop after its context was cancelled")
+	}
+	// Nothing more is sent once cancelled, the channel is only closed
+	for frame := range frameChan {
+		t.Errorf("loader sent %q after it stopped", frame.frame)
+	}
+}
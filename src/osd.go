@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// osdTimeout is how long the OSD stays up after the last key press
+const osdTimeout = 2 * time.Second
+
+// osdHideMsg hides the OSD unless a key press since has shown it again
+type osdHideMsg int
+
+// showOSD shows the OSD and schedules hiding it. Each call restarts the
+// timer, earlier hide messages are dropped by their generation.
+func (m *Model) showOSD() tea.Cmd {
+	m.osdVisible = true
+	m.osdGen++
+	gen := m.osdGen
+	return tea.Tick(osdTimeout, func(time.Time) tea.Msg {
+		return osdHideMsg(gen)
+	})
+}
+
+// osdRows returns the rows the OSD takes: the status line, the cue timeline
+// while subtitles are on, and the key hints
+func (m *Model) osdRows() int {
+	if m.subtitleMode > 0 {
+		return 3
+	}
+	return 2
+}
+
+// osd renders the on-screen display: a status line with the timecode, a
+// seek bar, the playlist entry, audio and subtitle state, a timeline of
+// subtitle cues under the seek bar, and a line of key hints
+func (m Model) osd() string {
+	icon := "▶"
+	if !m.playing {
+		icon = "⏸"
+	}
+	start := frameTime(m.clipStart)
+	end := frameTime(m.clipStart + m.clipFrames)
+	now := m.videoTime()
+
+	audio := "off"
+	switch {
+	case m.audioPlayer != nil && m.audioMuted:
+		audio = "muted"
+	case m.audioPlayer != nil:
+		audio = "on"
+	}
+	if m.audioPlayer != nil && m.audioDelay != 0 {
+		audio += fmt.Sprintf(" %+dms", m.audioDelay.Milliseconds())
+	}
+	subs := [...]string{"off", "JA", "EN"}[m.subtitleMode]
+	if label := subtitleLabels[[...]string{"", "ja", "en"}[m.subtitleMode]]; label != "" {
+		subs = label
+	}
+	if m.subtitleMode > 0 && m.subOffset != 0 {
+		subs += fmt.Sprintf(" %+.1fs", m.subOffset.Seconds())
+	}
+
+	left := fmt.Sprintf(" %s %s ", icon, formatTimestamp(now))
+	entry := ""
+	if len(playlist) > 0 {
+		entry = fmt.Sprintf(" %d/%d %s │", m.playlistIndex+1, len(playlist), playlist[m.playlistIndex].name())
+	}
+	right := fmt.Sprintf(" %s │%s audio %s │ subs %s ", formatTimestamp(end), entry, audio, subs)
+	barWidth := max(0, m.width-lipgloss.Width(left)-lipgloss.Width(right))
+	filled := 0
+	if end > start {
+		filled = min(barWidth, int(int64(barWidth)*int64(now-start)/int64(end-start)))
+	}
+	bar := m.theme.Highlight.Render(strings.Repeat("━", filled)) +
+		m.theme.Controls.Render(strings.Repeat("─", barWidth-filled))
+	status := m.theme.Controls.Render(left) + bar + m.theme.Controls.Render(right)
+	if m.subtitleMode > 0 {
+		status += "\n" + strings.Repeat(" ", lipgloss.Width(left)) + m.cueTimeline(barWidth, start, end, now)
+	}
+
+	hints := fmt.Sprintf("[%s] play/pause | [%s] seek | [%s] reset | [%s] subtitles | [%s] quit",
+		m.keys.hint(actionPlayPause), m.keys.hint(actionSeekForward), m.keys.hint(actionReset),
+		m.keys.hint(actionSubtitles), m.keys.hint(actionQuit))
+	hints = lipgloss.PlaceHorizontal(m.width, lipgloss.Center, m.theme.Controls.Render(ansi.Truncate(hints, m.width, "…")))
+	return status + "\n" + hints
+}
+
+// cueTimeline marks where subtitle cues start along a seek bar of width
+// cells spanning start to end, with a caret under the current position.
+// Cues still to come are highlighted so the next caption can be seen coming.
+func (m Model) cueTimeline(width int, start, end, now time.Duration) string {
+	if width <= 0 || end <= start {
+		return ""
+	}
+	column := func(t time.Duration) int {
+		return min(width-1, int(int64(width)*int64(t-start)/int64(end-start)))
+	}
+	cells := make([]string, width)
+	for i := range cells {
+		cells[i] = " "
+	}
+	for _, cue := range m.activeSubtitles() {
+		// Mark cues where they're shown, after the subtitle offset
+		shown := cue.StartTime + m.subOffset
+		if shown < start || shown >= end {
+			continue
+		}
+		if shown > now {
+			cells[column(shown)] = m.theme.Highlight.Render("╹")
+		} else {
+			cells[column(shown)] = m.theme.Controls.Render("╹")
+		}
+	}
+	if now >= start && now < end {
+		cells[column(now)] = m.theme.Highlight.Render("▲")
+	}
+	return strings.Join(cells, "")
+}
+
+// overlayBottom draws overlay over the last rows of a view filling height
+// rows, so the OSD covers the bottom of the video instead of resizing it
+func overlayBottom(view, overlay string, height int) string {
+	lines := strings.Split(strings.TrimSuffix(view, "\n"), "\n")
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	lines = lines[:height]
+	rows := strings.Split(overlay, "\n")
+	copy(lines[max(0, height-len(rows)):], rows)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// playlistEntry is one video of a -playlist
+type playlistEntry struct {
+	frames string   // frames directory
+	audio  string   // MP3 file, or empty to play silently
+	ja, en string   // subtitle files, or empty for no track
+	subs   []string // subtitle files put on a track by their language
+}
+
+// name returns how the entry is shown in the OSD
+func (e playlistEntry) name() string {
+	return filepath.Base(e.frames)
+}
+
+// playlist holds the videos played in turn with -playlist, nil otherwise
+var playlist []playlistEntry
+
+// loadPlaylist reads a playlist with one video per line: a frames directory
+// followed by optional audio=FILE, ja=FILE, en=FILE and subs=FILE fields,
+// subs going on the track of the language detected. Relative paths
+// are relative to the playlist. Blank lines and lines starting with # are
+// skipped.
+func loadPlaylist(path string) ([]playlistEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var entries []playlistEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entry := playlistEntry{frames: resolve(fields[0])}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("%s:%d: invalid field %q (want key=file)", path, line, field)
+			}
+			switch key {
+			case "audio":
+				entry.audio = resolve(value)
+			case "ja":
+				entry.ja = resolve(value)
+			case "en":
+				entry.en = resolve(value)
+			case "subs":
+				entry.subs = append(entry.subs, resolve(value))
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown field %q (want audio, ja, en or subs)", path, line, key)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading playlist: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("playlist %s is empty", path)
+	}
+	return entries, nil
+}
+
+// useEntry points the frame loader, audio and subtitles at a playlist entry
+// and returns its frame count
+func useEntry(e playlistEntry) (int, error) {
+	frameDir, audioFile = e.frames, e.audio
+	sourceCache.reset()
+	subtitleOverrides = map[string]string{"ja": e.ja, "en": e.en}
+	subtitleLabels = map[string]string{}
+	for _, path := range e.subs {
+		if err := addSubtitleFile(path); err != nil {
+			return 0, err
+		}
+	}
+	count, err := openFrames()
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no frames found in %s", e.frames)
+	}
+	clipFrom, clipTo = 1, count
+	return count, nil
+}
+
+// hasNextEntry reports whether the video after this one should play at the
+// end of the current one, rather than looping it or quitting
+func (m *Model) hasNextEntry() bool {
+	if len(playlist) < 2 {
+		return false
+	}
+	return !m.once || m.playlistIndex < len(playlist)-1
+}
+
+// playEntry stops the current video and starts playlist entry i, wrapping
+// around at either end. Playback starts once its first frames are loaded,
+// like the first video. fadeFrom, if any, are the previous video's frames
+// to crossfade from.
+func (m *Model) playEntry(i int, fadeFrom []string) tea.Cmd {
+	if len(playlist) == 0 {
+		return nil
+	}
+	i = (i%len(playlist) + len(playlist)) % len(playlist)
+	if m.cancelLoading != nil {
+		m.cancelLoading()
+	}
+	m.stopAudio()
+	m.audioStarted = false
+
+	count, err := useEntry(playlist[i])
+	if err != nil {
+		log.Errorf("could not play %s: %v", playlist[i].frames, err)
+		m.close()
+		return tea.Quit
+	}
+	m.playlistIndex = i
+	// Don't fade over more than half of a short entry
+	m.fadeFrom = fadeFrom[len(fadeFrom)-min(len(fadeFrom), count/2):]
+	m.subtitlesJA = m.loadEntrySubtitles("ja")
+	m.subtitlesEN = m.loadEntrySubtitles("en")
+	m.currentCues = nil
+	m.lastCue = 0
+
+	m.frames = nil
+	m.frameCount, m.loaded, m.totalFrames, m.currentFrame = 0, 0, 0, 0
+	m.clipStart, m.clipFrames = 0, count
+	m.playing, m.buffering = false, false
+	m.loopResumeAt = time.Time{}
+	// Force a reload even if the video size is unchanged
+	m.videoWidth = 0
+	return m.layout()
+}
+
+// loadEntrySubtitles loads a subtitle track of the current entry, logging
+// rather than failing if it can't be read
+func (m *Model) loadEntrySubtitles(lang string) []Subtitle {
+	subs, err := loadSubtitles(lang)
+	if err != nil {
+		log.Errorf("could not load %s subtitles: %v", lang, err)
+	}
+	return subs
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+)
+
+// arg for the file SSH viewers' preferences are kept in, or "" to not keep
+// them
+var sshPrefsPath string
+
+// maxViewerPrefs bounds the store, forgetting the viewers seen longest ago
+const maxViewerPrefs = 1000
+
+// viewerPrefs is what is remembered about a viewer between SSH sessions
+type viewerPrefs struct {
+	SubtitleMode int       `json:"subtitle_mode"`
+	Volume       float64   `json:"volume"`
+	Position     float64   `json:"position_seconds"`
+	Seen         time.Time `json:"seen"`
+}
+
+// prefsStore keeps viewers' preferences by public key fingerprint in a JSON
+// file, rewritten on every save
+type prefsStore struct {
+	path    string
+	mu      sync.Mutex
+	viewers map[string]viewerPrefs
+}
+
+// viewerStore holds SSH viewers' preferences with -ssh-prefs, nil otherwise
+var viewerStore *prefsStore
+
+// loadPrefsStore reads the store at path, starting empty if it doesn't
+// exist yet
+func loadPrefsStore(path string) (*prefsStore, error) {
+	store := &prefsStore{path: path, viewers: map[string]viewerPrefs{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.viewers); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// get returns a viewer's preferences, reporting false for a new viewer
+func (s *prefsStore) get(key string) (viewerPrefs, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.viewers[key]
+	return p, ok
+}
+
+// put saves a viewer's preferences, forgetting the viewer seen longest ago
+// if the store is full
+func (s *prefsStore) put(key string, p viewerPrefs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.Seen = time.Now()
+	s.viewers[key] = p
+	for len(s.viewers) > maxViewerPrefs {
+		oldest := key
+		for k, v := range s.viewers {
+			if v.Seen.Before(s.viewers[oldest].Seen) {
+				oldest = k
+			}
+		}
+		delete(s.viewers, oldest)
+	}
+
+	data, err := json.MarshalIndent(s.viewers, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write beside the store and rename, so a crash can't leave it half written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".prefs-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// keyFingerprint returns the SHA256 fingerprint of the key a session
+// authenticated with, as ssh-keygen -l shows it, or "" without one
+func keyFingerprint(s ssh.Session) string {
+	key := s.PublicKey()
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// sessionPrefs is the latest state of a session's player, shared between
+// the model's copies and the goroutine saving it on disconnect
+type sessionPrefs struct {
+	mu     sync.Mutex
+	prefs  viewerPrefs
+	played bool // the player has started, so there is something to save
+}
+
+// prefsModel wraps a session's model to note its player's state after each
+// message, to be saved when the viewer disconnects
+type prefsModel struct {
+	model   tea.Model
+	session *sessionPrefs
+}
+
+func (m prefsModel) Init() tea.Cmd {
+	return m.model.Init()
+}
+
+// Update passes every message to the wrapped model, then notes the state
+// of the player once the menu, if any, has handed off to it
+func (m prefsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.model, cmd = m.model.Update(msg)
+	if player, ok := m.model.(Model); ok && player.frameCount > 0 {
+		m.session.mu.Lock()
+		m.session.prefs = viewerPrefs{
+			SubtitleMode: player.subtitleMode,
+			Volume:       player.volume,
+			Position:     player.videoTime().Seconds(),
+		}
+		m.session.played = true
+		m.session.mu.Unlock()
+	}
+	return m, cmd
+}
+
+func (m prefsModel) View() string {
+	return m.model.View()
+}
+
+// withViewerPrefs applies a returning viewer's preferences to the session's
+// model and wraps it to save them again on disconnect. Sessions without a
+// public key are left as they are.
+func withViewerPrefs(s ssh.Session, model tea.Model) tea.Model {
+	key := keyFingerprint(s)
+	if viewerStore == nil || key == "" {
+		return model
+	}
+	if p, ok := viewerStore.get(key); ok {
+		switch m := model.(type) {
+		case Model:
+			m.subtitleMode = p.SubtitleMode
+			m.volume = p.Volume
+			m.resumeAt = time.Duration(p.Position * float64(time.Second))
+			model = m
+		case MenuModel:
+			m.volume = p.Volume
+			model = m
+		}
+		log.Info("returning viewer", "key", key, "position", formatTimestamp(time.Duration(p.Position*float64(time.Second))))
+	}
+
+	session := &sessionPrefs{}
+	go func() {
+		<-s.Context().Done()
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if !session.played {
+			return
+		}
+		if err := viewerStore.put(key, session.prefs); err != nil {
+			log.Error("could not save viewer preferences", "key", key, "error", err)
+		}
+	}()
+	return prefsModel{model: model, session: session}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// prerenderedRoot is where -prerendered frames are read from, or "" to
+// render every frame from its image
+var prerenderedRoot string
+
+// prerenderKey names the directory of frames pre-rendered with opts. Frames
+// only match a player rendering the same way at the same size.
+func prerenderKey(opts renderOptions) string {
+	mode := "ascii"
+	switch {
+	case opts.subpixel:
+		mode = "subpixel"
+	case opts.halfblock:
+		mode = "halfblock"
+	case opts.asciiSafe:
+		mode = "ascii-safe"
+	}
+	if opts.lightTerm {
+		mode += "-light"
+	}
+	return fmt.Sprintf("%s-%s-%dx%d", mode, opts.fit, opts.width, opts.height)
+}
+
+// prerenderedFile returns the file of the frameNum-th frame of the clip
+// pre-rendered with opts under root. Files are numbered in the full video so
+// they serve any -from/-to clip.
+func prerenderedFile(root string, frameNum int, opts renderOptions) string {
+	return filepath.Join(root, prerenderKey(opts), fmt.Sprintf("%06d.txt", clipFrom-1+frameNum))
+}
+
+// loadPrerendered reads a pre-rendered frame, reporting false if there isn't
+// one for the way the player renders
+func loadPrerendered(frameNum int, opts renderOptions) (string, bool) {
+	if prerenderedRoot == "" || opts.graphics != "" {
+		return "", false
+	}
+	frame, err := os.ReadFile(prerenderedFile(prerenderedRoot, frameNum, opts))
+	if err != nil {
+		return "", false
+	}
+	return string(frame), true
+}
+
+// prerender renders every frame of the clip with opts into a directory under
+// root, across all CPUs, for a player started with -prerendered root
+func prerender(root string, opts renderOptions, frameCount int) error {
+	dir := filepath.Join(root, prerenderKey(opts))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	frames := make(chan int)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range frames {
+				frame, _, err := renderFrame(i, opts)
+				if err == nil {
+					err = os.WriteFile(prerenderedFile(root, i, opts), []byte(frame), 0o644)
+				}
+				if err != nil {
+					select {
+					case errs <- fmt.Errorf("frame %d: %w", i, err):
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	var err error
+	for i := 1; i <= frameCount && err == nil; i++ {
+		select {
+		case frames <- i:
+		case err = <-errs:
+		}
+	}
+	close(frames)
+	wg.Wait()
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !silentOutput {
+		fmt.Printf("Rendered %d frames to %s\n", frameCount, dir)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"senshukai/render"
+)
+
+// Size of each panel in the palette preview
+const (
+	previewWidth  = 40
+	previewHeight = 20
+)
+
+// previewPanel is one labeled render of the frame in the palette preview
+type previewPanel struct {
+	label string
+	lines []string
+}
+
+// printPalettePreview renders one frame with each text render path side by
+// side, so settings can be compared without starting the player. Graphics
+// protocols draw over the cursor position and can't be laid out in columns,
+// so they are left out.
+func printPalettePreview(filename string, bg color.Color) error {
+	if plainOutput {
+		return fmt.Errorf("the palette preview is drawn in color, so it needs escape sequences")
+	}
+	img, err := loadGrayFrame(filename, bg)
+	if err != nil {
+		return err
+	}
+
+	b := img.Bounds()
+	width, height := containSize(b.Dx(), b.Dy(), previewWidth, previewHeight)
+	light := lightTerminal(lipgloss.DefaultRenderer())
+
+	panels := []previewPanel{
+		{"ASCII", render.Blocks(img, width, height, light)},
+		{"ASCII-safe", strings.Split(render.RampString(img, width, height, render.ASCIIRamp, light), "\n")},
+		{"half-block", renderHalfBlocks(img, width, height, thresholdOff)},
+		{"subpixel", renderSubpixel(img, width, height)},
+	}
+	if colorThreshold != thresholdOff {
+		label := fmt.Sprintf("threshold %d", colorThreshold)
+		if colorThreshold == thresholdAuto {
+			label = "threshold auto"
+		}
+		panels = append(panels, previewPanel{
+			label,
+			renderHalfBlocks(img, width, height, colorThreshold),
+		})
+	}
+
+	label := lipgloss.NewStyle().Bold(true)
+	columns := make([]string, 0, len(panels)*2)
+	for i, panel := range panels {
+		if i > 0 {
+			columns = append(columns, "  ")
+		}
+		columns = append(columns, lipgloss.JoinVertical(lipgloss.Left,
+			label.Render(panel.label),
+			strings.Join(panel.lines, "\n"),
+		))
+	}
+
+	fmt.Println(lipgloss.JoinHorizontal(lipgloss.Top, columns...))
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts a CPU profile to cpuPath if it's set. The returned
+// function stops it and writes a heap profile to memPath if that's set, and
+// must run before exit for either profile to be complete.
+func startProfiles(cpuPath, memPath string) (func(), error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		var err error
+		cpuFile, err = os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not create cpu profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("could not start cpu profile: %w", err)
+		}
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if memPath == "" {
+			return
+		}
+		file, err := os.Create(memPath)
+		if err != nil {
+			fmt.Printf("Error: could not create memory profile: %v\n", err)
+			return
+		}
+		defer file.Close()
+		// Collect garbage first so the profile shows live memory
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(file); err != nil {
+			fmt.Printf("Error: could not write memory profile: %v\n", err)
+		}
+	}, nil
+}
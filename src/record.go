@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// recordingsDir is where SSH session recordings are written
+const recordingsDir = "recordings"
+
+// recordMiddleware records the output of each session as an asciinema cast,
+// deleting the oldest recordings to keep the total under limit bytes.
+// Output is captured from the session's writer, which Bubble Tea writes to
+// since the server emulates PTYs.
+func recordMiddleware(limit int64) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if err := pruneRecordings(recordingsDir, limit); err != nil {
+				log.Warn("could not prune recordings", "error", err)
+			}
+			rec, err := newCastRecorder(s)
+			if err != nil {
+				log.Error("could not record session", "error", err)
+				next(s)
+				return
+			}
+			defer rec.Close()
+			next(rec)
+		}
+	}
+}
+
+// castRecorder is a session that copies its output to an asciinema v2 cast
+type castRecorder struct {
+	ssh.Session
+	mu      sync.Mutex
+	file    *os.File
+	enc     *json.Encoder
+	start   time.Time
+	pending []byte // start of a UTF-8 sequence split across writes
+}
+
+// newCastRecorder creates recordings/<timestamp>-<remote>.cast and writes
+// the cast header
+func newCastRecorder(s ssh.Session) (*castRecorder, error) {
+	if err := os.MkdirAll(recordingsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating recordings directory: %w", err)
+	}
+
+	start := time.Now()
+	remote := strings.NewReplacer(":", "-", "[", "", "]", "").Replace(s.RemoteAddr().String())
+	name := filepath.Join(recordingsDir, start.Format("20060102T150405")+"-"+remote+".cast")
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording: %w", err)
+	}
+
+	pty, _, _ := s.Pty()
+	rec := &castRecorder{Session: s, file: file, enc: json.NewEncoder(file), start: start}
+	header := map[string]any{
+		"version":   2,
+		"width":     pty.Window.Width,
+		"height":    pty.Window.Height,
+		"timestamp": start.Unix(),
+		"env":       map[string]string{"TERM": pty.Term},
+	}
+	if err := rec.enc.Encode(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error writing recording: %w", err)
+	}
+	return rec, nil
+}
+
+// Write sends output to the client and records it as an output event
+func (r *castRecorder) Write(p []byte) (int, error) {
+	n, err := r.Session.Write(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.pending, p[:n]...)
+	// Hold back an incomplete trailing rune so events stay valid UTF-8
+	cut := len(data)
+	for i := max(0, len(data)-utf8.UTFMax+1); i < len(data); i++ {
+		if utf8.RuneStart(data[i]) && !utf8.FullRune(data[i:]) {
+			cut = i
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		event := []any{time.Since(r.start).Seconds(), "o", string(data[:cut])}
+		if encErr := r.enc.Encode(event); encErr != nil {
+			log.Warn("could not write recording", "error", encErr)
+		}
+	}
+	return n, err
+}
+
+// Close finishes the recording
+func (r *castRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// pruneRecordings deletes the oldest casts in dir until their total size is
+// at most limit bytes
+func pruneRecordings(dir string, limit int64) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type recording struct {
+		name string
+		size int64
+	}
+	var recordings []recording
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".cast" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		recordings = append(recordings, recording{entry.Name(), info.Size()})
+		total += info.Size()
+	}
+
+	// Names start with a timestamp, so they sort oldest first
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].name < recordings[j].name })
+	for _, rec := range recordings {
+		if total <= limit {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, rec.name)); err != nil {
+			return err
+		}
+		total -= rec.size
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package render turns grayscale images into terminal text. It only uses the
+// standard library so it can also be built for WebAssembly.
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Gray converts an image to grayscale, returning it as is if it already is
+func Gray(img image.Image) *image.Gray {
+	switch src := img.(type) {
+	case *image.Gray:
+		return src
+	case *image.Paletted:
+		return grayPaletted(src)
+	}
+	// draw converts in bulk, several times faster than a Set per pixel
+	bounds := img.Bounds()
+	grayImg := image.NewGray(bounds)
+	draw.Draw(grayImg, bounds, img, bounds.Min, draw.Src)
+	return grayImg
+}
+
+// grayPaletted converts a paletted image by converting its palette once and
+// looking up every pixel's index
+func grayPaletted(img *image.Paletted) *image.Gray {
+	var lut [256]uint8
+	for i, c := range img.Palette {
+		lut[i] = color.GrayModel.Convert(c).(color.Gray).Y
+	}
+	grayImg := image.NewGray(img.Bounds())
+	for y := 0; y < img.Rect.Dy(); y++ {
+		src := img.Pix[y*img.Stride : y*img.Stride+img.Rect.Dx()]
+		dst := grayImg.Pix[y*grayImg.Stride:]
+		for x, index := range src {
+			dst[x] = lut[index]
+		}
+	}
+	return grayImg
+}
+
+// blockBufs holds the buffers frames are rendered into, reused across frames
+// so rendering at 60 FPS allocates little more than the finished string
+var blockBufs = sync.Pool{New: func() any { return new([]byte) }}
+
+// Ramp is the characters pixels are drawn with, densest first, each
+// covering an equal share of the gray levels from black to white
+type Ramp []rune
+
+// Ramps to draw with. BlockRamp's shade blocks need a Unicode font that has
+// them; ASCIIRamp matches their density with characters any terminal shows.
+var (
+	BlockRamp = Ramp("█▓▒░    ")
+	ASCIIRamp = Ramp("@%+:    ")
+)
+
+// rune returns the character for a gray value. light flips the shading.
+func (r Ramp) rune(pixel uint8, light bool) rune {
+	if light {
+		pixel = 255 - pixel
+	}
+	return r[int(pixel)*len(r)/256]
+}
+
+// Blocks renders an image as lines of shaded block characters, darker pixels
+// drawn with denser blocks. Images that aren't *image.Gray are converted
+// first. light flips the shading to match a terminal with a light background.
+func Blocks(img image.Image, targetWidth, targetHeight int, light bool) []string {
+	if targetHeight <= 0 {
+		return nil
+	}
+	return strings.Split(BlocksString(img, targetWidth, targetHeight, light), "\n")
+}
+
+// BlocksString renders an image like Blocks, with the lines joined by
+// newlines. The frame is written into a pooled buffer, so the only
+// allocation is the returned string.
+func BlocksString(img image.Image, targetWidth, targetHeight int, light bool) string {
+	return RampString(img, targetWidth, targetHeight, BlockRamp, light)
+}
+
+// RampString renders an image like BlocksString, drawing with the characters
+// of ramp instead of shade blocks
+func RampString(img image.Image, targetWidth, targetHeight int, ramp Ramp, light bool) string {
+	bufp := blockBufs.Get().(*[]byte)
+	buf := appendBlocks((*bufp)[:0], img, targetWidth, targetHeight, ramp, light)
+	frame := string(buf)
+	*bufp = buf
+	blockBufs.Put(bufp)
+	return frame
+}
+
+// appendBlocks appends the rendered lines of an image to buf, separated by
+// newlines
+func appendBlocks(buf []byte, img image.Image, targetWidth, targetHeight int, ramp Ramp, light bool) []byte {
+	// Sample Pix directly, going through At for every pixel costs an
+	// interface call and a color conversion each
+	gray := Gray(img)
+	b := gray.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+
+	// Ramp runes are at most three bytes in the ramps above, plus a newline
+	// per line
+	buf = slices.Grow(buf, targetHeight*(targetWidth*3+1))
+
+	// Determine if we need to scale down (terminal smaller than source)
+	scaleDown := targetWidth < srcW || targetHeight < srcH
+
+	if scaleDown {
+		// For downscaling, use simple nearest neighbor for better performance
+		for y := 0; y < targetHeight; y++ {
+			if y > 0 {
+				buf = append(buf, '\n')
+			}
+			for x := 0; x < targetWidth; x++ {
+				// Map target coordinates to source coordinates
+				srcX := (x * srcW) / targetWidth
+				srcY := (y * srcH) / targetHeight
+
+				// Clamp to source bounds
+				if srcX >= srcW {
+					srcX = srcW - 1
+				}
+				if srcY >= srcH {
+					srcY = srcH - 1
+				}
+
+				pixel := gray.Pix[srcY*gray.Stride+srcX]
+				buf = utf8.AppendRune(buf, ramp.rune(pixel, light))
+			}
+		}
+	} else {
+		// For upscaling, use bilinear interpolation for smooth results
+		for y := 0; y < targetHeight; y++ {
+			if y > 0 {
+				buf = append(buf, '\n')
+			}
+			for x := 0; x < targetWidth; x++ {
+				// Calculate source coordinates with floating point precision
+				srcX := float64(x) * float64(srcW) / float64(targetWidth)
+				srcY := float64(y) * float64(srcH) / float64(targetHeight)
+
+				// Get interpolated pixel value
+				pixel := bilinearInterpolate(gray, srcX, srcY, srcW, srcH)
+				buf = utf8.AppendRune(buf, ramp.rune(pixel, light))
+			}
+		}
+	}
+
+	return buf
+}
+
+// bilinearInterpolate samples a grayscale image between pixels
+func bilinearInterpolate(img *image.Gray, x, y float64, maxW, maxH int) uint8 {
+	// Get the four surrounding pixels
+	x0 := int(x)
+	y0 := int(y)
+	x1 := x0 + 1
+	y1 := y0 + 1
+
+	// Clamp coordinates
+	if x1 >= maxW {
+		x1 = maxW - 1
+	}
+	if y1 >= maxH {
+		y1 = maxH - 1
+	}
+
+	// Get pixel values
+	row0, row1 := img.Pix[y0*img.Stride:], img.Pix[y1*img.Stride:]
+	p00 := row0[x0]
+	p01 := row1[x0]
+	p10 := row0[x1]
+	p11 := row1[x1]
+
+	// Calculate interpolation weights
+	fx := x - float64(x0)
+	fy := y - float64(y0)
+
+	// Bilinear interpolation
+	val := uint8(
+		float64(p00)*(1-fx)*(1-fy) +
+			float64(p10)*fx*(1-fy) +
+			float64(p01)*(1-fx)*fy +
+			float64(p11)*fx*fy,
+	)
+
+	return val
+}
@@ -0,0 +1,167 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// gradientFrame returns a w x h frame shading diagonally from black to white
+func gradientFrame(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Pix[y*img.Stride+x] = uint8((x + y) * 255 / (w + h - 2))
+		}
+	}
+	return img
+}
+
+// atBlocks renders like BlocksString but reads every sample through At, as
+// Blocks did before sampling Pix directly
+func atBlocks(img image.Image, targetWidth, targetHeight int) string {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	gray := func(x, y int) uint8 { return img.At(x, y).(color.Gray).Y }
+	var sb strings.Builder
+	for y := range targetHeight {
+		if y > 0 {
+			sb.WriteByte('\n')
+		}
+		for x := range targetWidth {
+			var pixel uint8
+			if targetWidth < srcW || targetHeight < srcH {
+				pixel = gray(min(x*srcW/targetWidth, srcW-1), min(y*srcH/targetHeight, srcH-1))
+			} else {
+				fx := float64(x) * float64(srcW) / float64(targetWidth)
+				fy := float64(y) * float64(srcH) / float64(targetHeight)
+				x0, y0 := int(fx), int(fy)
+				x1, y1 := min(x0+1, srcW-1), min(y0+1, srcH-1)
+				fx, fy = fx-float64(x0), fy-float64(y0)
+				pixel = uint8(float64(gray(x0, y0))*(1-fx)*(1-fy) +
+					float64(gray(x1, y0))*fx*(1-fy) +
+					float64(gray(x0, y1))*(1-fx)*fy +
+					float64(gray(x1, y1))*fx*fy)
+			}
+			sb.WriteRune(BlockRamp.rune(pixel, false))
+		}
+	}
+	return sb.String()
+}
+
+// blockSizes are frame sizes that scale a 480x360 frame down and up
+var blockSizes = []struct {
+	name string
+	w, h int
+}{
+	{"down", 200, 60},
+	{"up", 600, 400},
+}
+
+func TestBlocksMatchesAt(t *testing.T) {
+	img := gradientFrame(480, 360)
+	for _, size := range blockSizes {
+		if got, want := BlocksString(img, size.w, size.h, false), atBlocks(img, size.w, size.h); got != want {
+			t.Errorf("scaling %s: Blocks and the At reference differ", size.name)
+		}
+	}
+}
+
+func BenchmarkBlocks(b *testing.B) {
+	img := gradientFrame(480, 360)
+	for _, size := range blockSizes {
+		b.Run(fmt.Sprintf("%s/pix", size.name), func(b *testing.B) {
+			for b.Loop() {
+				BlocksString(img, size.w, size.h, false)
+			}
+		})
+		b.Run(fmt.Sprintf("%s/at", size.name), func(b *testing.B) {
+			for b.Loop() {
+				atBlocks(img, size.w, size.h)
+			}
+		})
+	}
+}
+
+// grayInputs returns a 960x720 frame as each kind of image Gray converts
+func grayInputs() map[string]image.Image {
+	gray := gradientFrame(960, 720)
+	bounds := gray.Bounds()
+	rgba := image.NewRGBA(bounds)
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.RGBA{uint8(i), uint8(255 - i), uint8(i / 2), 255}
+	}
+	paletted := image.NewPaletted(bounds, palette)
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			v := gray.Pix[y*gray.Stride+x]
+			rgba.Set(x, y, color.RGBA{v, 255 - v, v / 2, 255})
+			paletted.SetColorIndex(x, y, v)
+		}
+	}
+	return map[string]image.Image{"rgba": rgba, "paletted": paletted, "gray": gray}
+}
+
+// setGray converts an image to gray one Set per pixel, as Gray did before
+// converting in bulk
+func setGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	grayImg := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			grayImg.Set(x, y, img.At(x, y))
+		}
+	}
+	return grayImg
+}
+
+func TestGrayMatchesSet(t *testing.T) {
+	for name, img := range grayInputs() {
+		got, want := Gray(img), setGray(img)
+		if !got.Bounds().Eq(want.Bounds()) || string(got.Pix) != string(want.Pix) {
+			t.Errorf("%s: Gray differs from converting each pixel", name)
+		}
+	}
+}
+
+func BenchmarkGray(b *testing.B) {
+	inputs := grayInputs()
+	for _, name := range []string{"rgba", "paletted", "gray"} {
+		img := inputs[name]
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				Gray(img)
+			}
+		})
+		if name == "gray" {
+			// Already gray is returned as is, there's nothing to compare
+			continue
+		}
+		b.Run(name+"/set", func(b *testing.B) {
+			for b.Loop() {
+				setGray(img)
+			}
+		})
+	}
+}
+
+func BenchmarkBlocksAllocs(b *testing.B) {
+	// Rendering into the pooled buffer allocates only the finished frame.
+	// Splitting it into Blocks' lines and joining them again costs more.
+	img := gradientFrame(480, 360)
+	b.Run("string", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			BlocksString(img, 200, 60, false)
+		}
+	})
+	b.Run("lines", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = strings.Join(Blocks(img, 200, 60, false), "\n")
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package render
+
+import "image"
+
+// Blend mixes src into dst in place, weighting src by weight from 0 (dst
+// unchanged) to 1 (all src). src is scaled to dst's size by nearest
+// neighbour so frames of different sizes can be mixed.
+func Blend(dst, src *image.Gray, weight float64) {
+	db, sb := dst.Bounds(), src.Bounds()
+	dstW, dstH := db.Dx(), db.Dy()
+	srcW, srcH := sb.Dx(), sb.Dy()
+	if srcW == 0 || srcH == 0 {
+		return
+	}
+	w := uint32(max(0, min(1, weight)) * 256)
+	for y := 0; y < dstH; y++ {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+dstW]
+		srcRow := src.Pix[(y*srcH/dstH)*src.Stride:]
+		for x, v := range row {
+			s := srcRow[x*srcW/dstW]
+			row[x] = uint8((uint32(v)*(256-w) + uint32(s)*w) >> 8)
+		}
+	}
+}
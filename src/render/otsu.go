@@ -0,0 +1,41 @@
+package render
+
+import "image"
+
+// Otsu picks the Threshold cutoff that best splits img into dark and light
+// pixels, by Otsu's method: the split that maximizes the variance between
+// the two classes' means. Images of a single gray level have no split and
+// get the midpoint, so they keep their level.
+func Otsu(img *image.Gray) uint8 {
+	var hist [256]int
+	for _, v := range img.Pix {
+		hist[v]++
+	}
+	total, sum := 0, 0
+	for v, n := range hist {
+		total += n
+		sum += v * n
+	}
+
+	cutoff, best := 128, 0.0
+	darkCount, darkSum := 0, 0
+	for t, n := range hist {
+		darkCount += n
+		if darkCount == 0 {
+			continue
+		}
+		lightCount := total - darkCount
+		if lightCount == 0 {
+			break
+		}
+		darkSum += t * n
+		darkMean := float64(darkSum) / float64(darkCount)
+		lightMean := float64(sum-darkSum) / float64(lightCount)
+		between := float64(darkCount) * float64(lightCount) * (darkMean - lightMean) * (darkMean - lightMean)
+		if between > best {
+			// Levels up to t are dark, so light starts just above
+			cutoff, best = t+1, between
+		}
+	}
+	return uint8(cutoff)
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"image"
+	"testing"
+)
+
+// levels returns an image with a pixel of each of values
+func levels(values ...uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, len(values), 1))
+	copy(img.Pix, values)
+	return img
+}
+
+func TestOtsuBimodal(t *testing.T) {
+	// Two clusters of grays, the dark one larger, split between them
+	var values []uint8
+	for v := uint8(20); v <= 24; v++ {
+		for range 30 {
+			values = append(values, v)
+		}
+	}
+	for v := uint8(200); v <= 206; v++ {
+		for range 10 {
+			values = append(values, v)
+		}
+	}
+	img := levels(values...)
+	cutoff := Otsu(img)
+	if cutoff <= 24 || cutoff > 200 {
+		t.Fatalf("Otsu = %d, want a cutoff between 24 and 200", cutoff)
+	}
+
+	Threshold(img, cutoff)
+	for i, v := range img.Pix {
+		want := uint8(0)
+		if values[i] >= 200 {
+			want = 255
+		}
+		if v != want {
+			t.Fatalf("gray %d thresholded to %d, want %d", values[i], v, want)
+		}
+	}
+}
+
+func TestOtsuSingleLevel(t *testing.T) {
+	// Nothing to split, so the midpoint keeps solid frames as they are
+	for _, v := range []uint8{0, 90, 255} {
+		if cutoff := Otsu(levels(v, v, v, v)); cutoff != 128 {
+			t.Errorf("Otsu of solid %d = %d, want 128", v, cutoff)
+		}
+	}
+}
+
+func TestOtsuBlackAndWhite(t *testing.T) {
+	// Pure black and white split just above black
+	if cutoff := Otsu(levels(0, 0, 255, 255, 255)); cutoff != 1 {
+		t.Errorf("Otsu of black and white = %d, want 1", cutoff)
+	}
+}
@@ -0,0 +1,112 @@
+package render
+
+import "image"
+
+// subpixelGrid is how many samples a cell is split into each way for
+// subpixel rendering, matching the eighth blocks' steps
+const subpixelGrid = 8
+
+// Cell is a character cell drawn in two grays: Fg where the character is
+// inked and Bg elsewhere
+type Cell struct {
+	Rune   rune
+	Fg, Bg uint8
+}
+
+// Block characters by how many eighths of the cell they ink, from the
+// bottom and from the left
+var (
+	lowerEighths = []rune(" ▁▂▃▄▅▆▇")
+	leftEighths  = []rune(" ▏▎▍▌▋▊▉")
+)
+
+// Quadrant characters and which quadrants they ink, as bits of upper left,
+// upper right, lower left and lower right. A shape's complement is the same
+// character with Fg and Bg swapped, so the three-quadrant characters and
+// the other diagonal aren't needed, as upper and right blocks aren't.
+var quadrants = []struct {
+	r    rune
+	bits int
+}{{'▘', 0b0001}, {'▝', 0b0010}, {'▖', 0b0100}, {'▗', 0b1000}, {'▚', 0b1001}}
+
+// Subpixel renders an image as cells of eighth blocks and quadrants in two
+// grays each, for smoother edges than whole or half blocks. Each cell takes
+// the shape that best splits its samples into a dark and a light part, so
+// a curve crossing a cell is drawn at its slope and position within it.
+func Subpixel(img *image.Gray, cols, rows int) [][]Cell {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	cells := make([][]Cell, rows)
+	if srcW == 0 || srcH == 0 {
+		for y := range cells {
+			cells[y] = make([]Cell, cols)
+		}
+		return cells
+	}
+
+	for cy := range rows {
+		cells[cy] = make([]Cell, cols)
+		for cx := range cols {
+			var sums cellSums
+			for sy := range subpixelGrid {
+				py := (cy*subpixelGrid + sy) * srcH / (rows * subpixelGrid)
+				row := img.Pix[py*img.Stride:]
+				for sx := range subpixelGrid {
+					v := int(row[(cx*subpixelGrid+sx)*srcW/(cols*subpixelGrid)])
+					sums.rows[sy] += v
+					sums.cols[sx] += v
+					sums.quads[sy/(subpixelGrid/2)*2+sx/(subpixelGrid/2)] += v
+				}
+			}
+			cells[cy][cx] = sums.fit()
+		}
+	}
+	return cells
+}
+
+// cellSums are a cell's samples summed by row, column and quadrant, which
+// is all fitting a shape to them takes
+type cellSums struct {
+	rows, cols [subpixelGrid]int
+	quads      [4]int
+}
+
+// fit picks the shape and two grays closest to a cell's samples. With each
+// side drawn at its mean, the squared error is smallest for the shape
+// maximizing sumIn²/nIn + sumOut²/nOut.
+func (s *cellSums) fit() Cell {
+	const n = subpixelGrid * subpixelGrid
+	total := 0
+	for _, v := range s.rows {
+		total += v
+	}
+	best := Cell{Rune: '█', Fg: uint8(total / n), Bg: uint8(total / n)}
+	bestScore := float64(total) * float64(total) / n
+	try := func(r rune, in, nIn int) {
+		out, nOut := total-in, n-nIn
+		score := float64(in)*float64(in)/float64(nIn) + float64(out)*float64(out)/float64(nOut)
+		if score > bestScore {
+			bestScore = score
+			best = Cell{Rune: r, Fg: uint8(in / nIn), Bg: uint8(out / nOut)}
+		}
+	}
+
+	lower, left := 0, 0
+	for k := 1; k < subpixelGrid; k++ {
+		lower += s.rows[subpixelGrid-k]
+		left += s.cols[k-1]
+		try(lowerEighths[k], lower, k*subpixelGrid)
+		try(leftEighths[k], left, k*subpixelGrid)
+	}
+	for _, q := range quadrants {
+		in, nIn := 0, 0
+		for i, v := range s.quads {
+			if q.bits&(1<<i) != 0 {
+				in += v
+				nIn += n / 4
+			}
+		}
+		try(q.r, in, nIn)
+	}
+	return best
+}
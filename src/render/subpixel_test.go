@@ -0,0 +1,41 @@
+package render
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSubpixelDiagonal(t *testing.T) {
+	// White above a diagonal from the top left corner to the bottom right,
+	// one pixel per sample over 4x4 cells
+	const cells = 4
+	size := cells * subpixelGrid
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			if x > y {
+				img.Pix[y*img.Stride+x] = 255
+			}
+		}
+	}
+
+	// Cells on the diagonal ink their upper right quadrant, the side of the
+	// edge holding most of the white, over the gray of the rest
+	onEdge := Cell{Rune: '▝', Fg: 255, Bg: 12 * 255 / 48}
+	got := Subpixel(img, cells, cells)
+	for cy := range cells {
+		for cx := range cells {
+			want := Cell{Rune: '█'}
+			switch {
+			case cx == cy:
+				want = onEdge
+			case cx > cy:
+				want.Fg, want.Bg = 255, 255
+			}
+			if got[cy][cx] != want {
+				t.Errorf("cell (%d, %d) = {%q %d %d}, want {%q %d %d}", cx, cy,
+					got[cy][cx].Rune, got[cy][cx].Fg, got[cy][cx].Bg, want.Rune, want.Fg, want.Bg)
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package render
+
+import "image"
+
+// Threshold turns img into pure black and white in place: pixels at or above
+// cutoff become white and the rest black
+func Threshold(img *image.Gray, cutoff uint8) {
+	for i, v := range img.Pix {
+		if v >= cutoff {
+			img.Pix[i] = 255
+		} else {
+			img.Pix[i] = 0
+		}
+	}
+}
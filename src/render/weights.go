@@ -0,0 +1,101 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// GrayWeights is how much each color channel adds to a pixel's brightness.
+// They are normalized to sum to 1, so white stays white.
+type GrayWeights struct {
+	R, G, B float64
+}
+
+// Standard luma weights. BT601 is what image/color uses and the default.
+var (
+	BT601 = GrayWeights{0.299, 0.587, 0.114}
+	BT709 = GrayWeights{0.2126, 0.7152, 0.0722}
+)
+
+// ParseGrayWeights parses "bt601", "bt709" or a custom "r,g,b" mix like
+// "1,0,0" for the red channel alone
+func ParseGrayWeights(s string) (GrayWeights, error) {
+	switch strings.ToLower(s) {
+	case "bt601":
+		return BT601, nil
+	case "bt709":
+		return BT709, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return GrayWeights{}, fmt.Errorf("gray weights %q must be bt601, bt709 or r,g,b", s)
+	}
+	var mix [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 {
+			return GrayWeights{}, fmt.Errorf("gray weight %q must be a non-negative number", part)
+		}
+		mix[i] = v
+	}
+	sum := mix[0] + mix[1] + mix[2]
+	if sum == 0 {
+		return GrayWeights{}, fmt.Errorf("gray weights %q can't all be 0", s)
+	}
+	return GrayWeights{mix[0] / sum, mix[1] / sum, mix[2] / sum}, nil
+}
+
+// GrayWeighted converts an image to grayscale with the given channel
+// weights, returning it as is if it already is grayscale. Transparent
+// pixels darken toward black, as with Gray.
+func GrayWeighted(img image.Image, w GrayWeights) *image.Gray {
+	if w == BT601 {
+		// image/color's conversion is the same and faster for most formats
+		return Gray(img)
+	}
+	if src, ok := img.(*image.Gray); ok {
+		return src
+	}
+
+	// Fixed point weights summing to 1<<16
+	wr := uint32(w.R*(1<<16) + 0.5)
+	wg := uint32(w.G*(1<<16) + 0.5)
+	wb := (1 << 16) - min(wr+wg, 1<<16)
+
+	bounds := img.Bounds()
+	grayImg := image.NewGray(bounds)
+	switch src := img.(type) {
+	case *image.RGBA:
+		// Already alpha-premultiplied, so darkened over black
+		for y := 0; y < bounds.Dy(); y++ {
+			row := src.Pix[y*src.Stride:]
+			dst := grayImg.Pix[y*grayImg.Stride:]
+			for x := range bounds.Dx() {
+				p := row[4*x:]
+				dst[x] = uint8((wr*uint32(p[0]) + wg*uint32(p[1]) + wb*uint32(p[2]) + 1<<15) >> 16)
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			row := src.Pix[y*src.Stride:]
+			dst := grayImg.Pix[y*grayImg.Stride:]
+			for x := range bounds.Dx() {
+				p := row[4*x:]
+				luma := (wr*uint32(p[0]) + wg*uint32(p[1]) + wb*uint32(p[2]) + 1<<15) >> 16
+				dst[x] = uint8(luma * uint32(p[3]) / 0xff)
+			}
+		}
+	default:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			dst := grayImg.Pix[(y-bounds.Min.Y)*grayImg.Stride:]
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				luma := (uint64(wr)*uint64(r) + uint64(wg)*uint64(g) + uint64(wb)*uint64(b) + 1<<15) >> 16
+				dst[x-bounds.Min.X] = uint8(luma >> 8)
+			}
+		}
+	}
+	return grayImg
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+func TestRenderHalfBlocks(t *testing.T) {
+	// A column of pixels, top then bottom of each cell
+	column := func(values ...uint8) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 1, len(values)))
+		copy(img.Pix, values)
+		return img
+	}
+	for _, tt := range []struct {
+		name      string
+		img       *image.Gray
+		threshold int
+		want      string
+	}{
+		{"black over white", column(0, 255), thresholdOff, "\033[38;5;16;48;5;231m▀\033[0m"},
+		{"gray over black", column(128, 0), thresholdOff, "\033[38;5;244;48;5;16m▀\033[0m"},
+		{"thresholded", column(128, 40), 100, "\033[38;5;231;48;5;16m▀\033[0m"},
+	} {
+		got := renderHalfBlocks(tt.img, 1, 1, tt.threshold)
+		if want := []string{tt.want}; !slices.Equal(got, want) {
+			t.Errorf("%s: rendered %q, want %q", tt.name, got, want)
+		}
+	}
+
+	// Cells in the same colors share one escape
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(img.Pix, []uint8{0, 0, 255, 255, 255, 0})
+	want := []string{"\033[38;5;16;48;5;231m▀▀\033[38;5;231;48;5;16m▀\033[0m"}
+	if got := renderHalfBlocks(img, 3, 1, thresholdOff); !slices.Equal(got, want) {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
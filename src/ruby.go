@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// rubyPattern matches a reading annotation: an HTML ruby tag, or kanji
+// followed by their kana reading in half- or full-width parentheses, like
+// 漢字(かんじ)
+var rubyPattern = regexp.MustCompile(
+	`<ruby>(.*?)(?:<rp>.*?</rp>)?<rt>(.*?)</rt>(?:<rp>.*?</rp>)?</ruby>` +
+		`|([\p{Han}々]+)[(（]([\p{Hiragana}\p{Katakana}ー]+)[)）]`)
+
+// rubySegment is a run of subtitle text with the reading shown above it,
+// if any
+type rubySegment struct {
+	base    string
+	reading string
+}
+
+// hasRuby reports whether a subtitle line carries readings
+func hasRuby(line string) bool {
+	return rubyPattern.MatchString(line)
+}
+
+// parseRuby splits a subtitle line into plain runs and annotated bases
+func parseRuby(line string) []rubySegment {
+	var segments []rubySegment
+	last := 0
+	for _, match := range rubyPattern.FindAllStringSubmatchIndex(line, -1) {
+		if match[0] > last {
+			segments = append(segments, rubySegment{base: line[last:match[0]]})
+		}
+		// The tag or the parenthesized form, whichever matched
+		base, reading := match[2:4], match[4:6]
+		if base[0] < 0 {
+			base, reading = match[6:8], match[8:10]
+		}
+		segments = append(segments, rubySegment{
+			base:    line[base[0]:base[1]],
+			reading: line[reading[0]:reading[1]],
+		})
+		last = match[1]
+	}
+	if last < len(line) {
+		segments = append(segments, rubySegment{base: line[last:]})
+	}
+	return segments
+}
+
+// renderRuby lays out a subtitle line as its readings centered over their
+// bases and the bases as plain text. Readings wider than their base spill
+// over its neighbours and are pushed right so they never overlap.
+func renderRuby(line string) (readings, bases string) {
+	var top, bottom strings.Builder
+	col, cursor := 0, 0
+	for _, seg := range parseRuby(line) {
+		w := lipgloss.Width(seg.base)
+		if seg.reading != "" {
+			start := max(cursor, col+(w-lipgloss.Width(seg.reading))/2)
+			top.WriteString(strings.Repeat(" ", start-cursor))
+			top.WriteString(seg.reading)
+			cursor = start + lipgloss.Width(seg.reading)
+		}
+		bottom.WriteString(seg.base)
+		col += w
+	}
+	return top.String(), bottom.String()
+}
+
+// rubyLines returns how many rows a subtitle's text takes, with a reading
+// row over each annotated line if ruby is on
+func rubyLines(text string, ruby bool) int {
+	lines := 0
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		lines++
+		if ruby && hasRuby(line) {
+			lines++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,46 @@
+package main
+
+import "image"
+
+// arg to only scale frames by whole numbers of cells per pixel, or pixels
+// per cell, keeping them crisp
+var integerScale bool
+
+// integerStep is how many source pixels one cell column covers, as a
+// fraction num/den. Rows cover twice as many, as cells are about twice as
+// tall as they are wide.
+type integerStep struct{ num, den int }
+
+// integerSize returns the largest cols x rows within maxCols x maxRows that
+// a srcW x srcH frame scales to by whole steps: each pixel drawn as k rows of
+// 2k columns, or each cell standing for n columns of 2n rows of pixels. The
+// aspect ratio is kept as containSize keeps it, but no pixel is ever blended
+// with its neighbours.
+func integerSize(srcW, srcH, maxCols, maxRows int) (cols, rows int, step integerStep) {
+	// Upscale by the largest k that fits
+	if k := min(maxCols/(2*srcW), maxRows/srcH); k >= 1 {
+		return 2 * k * srcW, k * srcH, integerStep{1, 2 * k}
+	}
+	// Otherwise downscale by the smallest n that fits
+	n := 1
+	for srcW/n > maxCols || srcH/(2*n) > maxRows {
+		n++
+	}
+	return max(1, srcW/n), max(1, srcH/(2*n)), integerStep{n, 1}
+}
+
+// scaleNearest samples img to cols x rows by step, so each cell of the
+// result maps to exactly one source pixel
+func scaleNearest(img *image.Gray, cols, rows int, step integerStep) *image.Gray {
+	b := img.Bounds()
+	scaled := image.NewGray(image.Rect(0, 0, cols, rows))
+	for y := 0; y < rows; y++ {
+		srcY := min(y*2*step.num/step.den, b.Dy()-1)
+		src := img.Pix[srcY*img.Stride:]
+		dst := scaled.Pix[y*scaled.Stride:]
+		for x := 0; x < cols; x++ {
+			dst[x] = src[min(x*step.num/step.den, b.Dx()-1)]
+		}
+	}
+	return scaled
+}
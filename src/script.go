@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// arg for the file of timed commands to drive playback with, or ""
+var scriptPath string
+
+// scriptMsg is a command from a -script, sent to the player when its time
+// comes
+type scriptMsg struct {
+	action string        // play, pause, seek, subs or quit
+	at     time.Duration // where to seek to
+	subs   int           // subtitle mode to switch to
+}
+
+// scriptStep is a command of a script and how long after the one before it
+// to run it
+type scriptStep struct {
+	wait time.Duration
+	cmd  scriptMsg
+}
+
+// scriptCommands lists the script commands and whether each takes an
+// argument
+var scriptCommands = map[string]bool{
+	"wait": true, "seek": true, "subs": true,
+	"play": false, "pause": false, "quit": false,
+}
+
+// parseScript parses a script of commands separated by semicolons or
+// newlines, with # starting a comment:
+//
+//	wait DURATION   pause the script, like 2s or 500ms
+//	seek TIME       jump to a time like 0:30, 1:02:03 or 90
+//	subs off|ja|en  show no, Japanese or English subtitles
+//	play / pause    resume or pause playback
+//	quit            stop the player
+//
+// Waits add up, so each command runs once every wait before it has passed.
+func parseScript(src string) ([]scriptStep, error) {
+	var steps []scriptStep
+	var wait time.Duration
+	for n, line := range strings.Split(src, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		for _, command := range strings.Split(line, ";") {
+			fields := strings.Fields(command)
+			if len(fields) == 0 {
+				continue
+			}
+			cmd, arg := fields[0], strings.Join(fields[1:], " ")
+			fail := func(format string, args ...any) error {
+				return fmt.Errorf("line %d: %s", n+1, fmt.Sprintf(format, args...))
+			}
+			takesArg, known := scriptCommands[cmd]
+			switch {
+			case !known:
+				return nil, fail("unknown command %q", cmd)
+			case takesArg && arg == "":
+				return nil, fail("%s needs an argument", cmd)
+			case !takesArg && arg != "":
+				return nil, fail("%s takes no argument", cmd)
+			}
+			switch cmd {
+			case "wait":
+				d, err := time.ParseDuration(arg)
+				if err != nil || d < 0 {
+					return nil, fail("invalid wait %q (want a duration like 2s or 500ms)", arg)
+				}
+				wait += d
+				continue
+			case "seek":
+				at, err := parseClock(arg)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				steps = append(steps, scriptStep{wait: wait, cmd: scriptMsg{action: cmd, at: at}})
+			case "subs":
+				mode := map[string]int{"off": 0, "ja": 1, "en": 2}
+				subs, ok := mode[arg]
+				if !ok {
+					return nil, fail("invalid subs %q (want off, ja or en)", arg)
+				}
+				steps = append(steps, scriptStep{wait: wait, cmd: scriptMsg{action: cmd, subs: subs}})
+			case "play", "pause", "quit":
+				steps = append(steps, scriptStep{wait: wait, cmd: scriptMsg{action: cmd}})
+			}
+			wait = 0
+		}
+	}
+	return steps, nil
+}
+
+// loadScript reads and parses a script file
+func loadScript(path string) ([]scriptStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := parseScript(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error in %s: %w", path, err)
+	}
+	return steps, nil
+}
+
+// runScript sends each step to the player in turn, after its wait, until
+// the script ends or ctx is done
+func runScript(ctx context.Context, steps []scriptStep, send func(tea.Msg)) {
+	for _, step := range steps {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(step.wait):
+		}
+		send(step.cmd)
+	}
+}
+
+// runScriptCommand runs a script command like the key bound to it would
+func (m Model) runScriptCommand(msg scriptMsg) (tea.Model, tea.Cmd) {
+	switch msg.action {
+	case "play", "pause":
+		return m, m.setPlaying(msg.action == "play")
+	case "seek":
+		m.seekTo(msg.at)
+		return m, m.refill()
+	case "subs":
+		m.subtitleMode = msg.subs
+		m.currentCues = nil
+		return m, m.layout()
+	case "quit":
+		m.close()
+		return m, tea.Quit
+	}
+	return m, nil
+}
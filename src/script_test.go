@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	step := func(wait time.Duration, cmd scriptMsg) scriptStep {
+		return scriptStep{wait: wait, cmd: cmd}
+	}
+	for _, tt := range []struct {
+		name string
+		src  string
+		want []scriptStep
+	}{
+		{"empty", "", nil},
+		{"comments and blank lines", "# a script\n\n   # indented\n", nil},
+		{
+			"every command",
+			"play\npause\nseek 1:02:03\nseek 90\nsubs off\nsubs ja\nsubs en\nquit\n",
+			[]scriptStep{
+				step(0, scriptMsg{action: "play"}),
+				step(0, scriptMsg{action: "pause"}),
+				step(0, scriptMsg{action: "seek", at: time.Hour + 2*time.Minute + 3*time.Second}),
+				step(0, scriptMsg{action: "seek", at: 90 * time.Second}),
+				step(0, scriptMsg{action: "subs", subs: 0}),
+				step(0, scriptMsg{action: "subs", subs: 1}),
+				step(0, scriptMsg{action: "subs", subs: 2}),
+				step(0, scriptMsg{action: "quit"}),
+			},
+		},
+		{
+			// Waits add up across semicolons and lines until a command
+			"waits",
+			"wait 1s; wait 500ms\nwait 250ms; pause # then stop\nwait 2s\n\nquit",
+			[]scriptStep{
+				step(1750*time.Millisecond, scriptMsg{action: "pause"}),
+				step(2*time.Second, scriptMsg{action: "quit"}),
+			},
+		},
+		{"trailing wait", "play; wait 5s", []scriptStep{step(0, scriptMsg{action: "play"})}},
+		{"extra spaces", "  seek   0:30  ;;subs   en", []scriptStep{
+			step(0, scriptMsg{action: "seek", at: 30 * time.Second}),
+			step(0, scriptMsg{action: "subs", subs: 2}),
+		}},
+	} {
+		got, err := parseScript(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: parsed %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"rewind", `line 1: unknown command "rewind"`},
+		{"play\n\nfast forward", `line 3: unknown command "fast"`},
+		{"wait", "line 1: wait needs an argument"},
+		{"wait soon", `line 1: invalid wait "soon"`},
+		{"wait -1s", `line 1: invalid wait "-1s"`},
+		{"play; seek", "line 1: seek needs an argument"},
+		{"# seek\nseek 1:xx", `line 2: invalid time "1:xx"`},
+		{"subs", "line 1: subs needs an argument"},
+		{"subs fr", `line 1: invalid subs "fr"`},
+		{"play\npause now", "line 2: pause takes no argument"},
+		{"quit 0", "line 1: quit takes no argument"},
+		{"play 2x", "line 1: play takes no argument"},
+	} {
+		_, err := parseScript(tt.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("parseScript(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxSearchResults caps how many matching cues are listed
+const maxSearchResults = 10
+
+// searchState holds the subtitle search box opened with '/'
+type searchState struct {
+	active     bool
+	input      textinput.Model
+	results    []Subtitle
+	cursor     int
+	wasPlaying bool // resume playback when the search closes
+}
+
+// openSearch pauses playback and focuses the search box
+func (m *Model) openSearch() {
+	input := textinput.New()
+	input.Placeholder = "search subtitles"
+	input.Prompt = "/"
+	input.Focus()
+
+	m.search = searchState{
+		active:     true,
+		input:      input,
+		wasPlaying: m.playing,
+	}
+	m.setPlaying(false)
+}
+
+// closeSearch hides the search box and resumes playback if it was running
+func (m *Model) closeSearch() tea.Cmd {
+	wasPlaying := m.search.wasPlaying
+	m.search = searchState{}
+	return m.setPlaying(wasPlaying)
+}
+
+// updateSearch handles keys while the search box is open
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.close()
+		return m, tea.Quit
+	case "esc":
+		return m, m.closeSearch()
+	case "up", "ctrl+p":
+		if m.search.cursor > 0 {
+			m.search.cursor--
+		}
+		return m, nil
+	case "down", "ctrl+n":
+		if m.search.cursor < len(m.search.results)-1 {
+			m.search.cursor++
+		}
+		return m, nil
+	case "enter":
+		if len(m.search.results) > 0 {
+			m.seekTo(m.search.results[m.search.cursor].StartTime + m.subOffset)
+		}
+		return m, m.closeSearch()
+	}
+
+	var cmd tea.Cmd
+	m.search.input, cmd = m.search.input.Update(msg)
+	m.search.results = searchSubtitles(m.searchTrack(), m.search.input.Value())
+	m.search.cursor = 0
+	return m, cmd
+}
+
+// searchTrack returns the cues to search: the active track, or both tracks
+// when subtitles are off
+func (m *Model) searchTrack() []Subtitle {
+	if subs := m.activeSubtitles(); subs != nil {
+		return subs
+	}
+	return append(append([]Subtitle{}, m.subtitlesJA...), m.subtitlesEN...)
+}
+
+// searchSubtitles returns cues whose text contains the query, ignoring case
+func searchSubtitles(subs []Subtitle, query string) []Subtitle {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var results []Subtitle
+	for _, sub := range subs {
+		if strings.Contains(strings.ToLower(sub.Text), query) {
+			results = append(results, sub)
+			if len(results) == maxSearchResults {
+				break
+			}
+		}
+	}
+	return results
+}
+
+// searchView renders the search box and matching cues
+func (m Model) searchView() string {
+	var view strings.Builder
+	view.WriteString(m.search.input.View())
+	view.WriteString("\n\n")
+
+	if len(m.search.results) == 0 && m.search.input.Value() != "" {
+		view.WriteString("  no matches\n")
+	}
+	for i, sub := range m.search.results {
+		cursor := "  "
+		if i == m.search.cursor {
+			cursor = "> "
+		}
+		// Show multi-line cues on a single row
+		text := strings.ReplaceAll(sub.Text, "\n", " / ")
+		fmt.Fprintf(&view, "%s%s  %s\n", cursor, formatTimestamp(sub.StartTime), text)
+	}
+
+	view.WriteString("\n")
+	view.WriteString(m.theme.Controls.Render("[enter] jump | [↑/↓] select | [esc] cancel"))
+	return view.String()
+}
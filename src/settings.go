@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Settings that can be changed from the settings panel
+const (
+	settingAudio     = "audio"
+	settingSubtitles = "subtitles"
+	settingColor     = "color"
+	settingSpeedRamp = "speed_ramp"
+	settingVolume    = "volume"
+	settingFPS       = "fps"
+)
+
+// fpsChoices are the frame rates the settings panel offers for 60 FPS
+// frames, the nth showing every nth frame
+var fpsChoices = []string{"60", "30", "20", "15"}
+
+// colorModes are the text render modes the settings panel switches between
+var colorModes = []string{"ASCII", "half-block", "subpixel"}
+
+// colorModeIndex returns the colorModes index of a render mode
+func colorModeIndex(halfblock, subpixel bool) int {
+	switch {
+	case subpixel:
+		return 2
+	case halfblock:
+		return 1
+	}
+	return 0
+}
+
+// volumeChoices are the volume steps the settings panel offers
+var volumeChoices = []string{"0%", "10%", "20%", "30%", "40%", "50%", "60%", "70%", "80%", "90%", "100%"}
+
+// setting is a row of the settings panel, cycling through values
+type setting struct {
+	id     string
+	label  string
+	values []string
+	index  int
+}
+
+// settingsPanel is a list of settings changed with the arrow keys. Changes
+// are reported with settingChangedMsg for the owner to apply right away.
+type settingsPanel struct {
+	active   bool
+	settings []setting
+	cursor   int
+	theme    Theme
+}
+
+// settingChangedMsg reports that a setting was changed to its index-th value
+type settingChangedMsg struct {
+	id    string
+	index int
+}
+
+// settingsClosedMsg reports that the settings panel was closed
+type settingsClosedMsg struct{}
+
+// newSettingsPanel opens a settings panel listing settings
+func newSettingsPanel(settings []setting, theme Theme) settingsPanel {
+	return settingsPanel{active: true, settings: settings, theme: theme}
+}
+
+// Update moves between settings and cycles the selected one
+func (p settingsPanel) Update(msg tea.Msg) (settingsPanel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	step := 0
+	switch key.String() {
+	case "esc", "q", "backspace":
+		p.active = false
+		return p, func() tea.Msg { return settingsClosedMsg{} }
+	case "up", "k":
+		p.cursor = max(0, p.cursor-1)
+	case "down", "j":
+		p.cursor = min(len(p.settings)-1, p.cursor+1)
+	case "left", "h":
+		step = -1
+	case "right", "l", "enter", " ":
+		step = 1
+	}
+	if step == 0 || len(p.settings) == 0 {
+		return p, nil
+	}
+
+	s := &p.settings[p.cursor]
+	s.index = (s.index + step + len(s.values)) % len(s.values)
+	changed := settingChangedMsg{id: s.id, index: s.index}
+	return p, func() tea.Msg { return changed }
+}
+
+// View lists the settings with their current values
+func (p settingsPanel) View() string {
+	labelWidth := 0
+	for _, s := range p.settings {
+		labelWidth = max(labelWidth, lipgloss.Width(s.label))
+	}
+	lines := []string{"Settings", ""}
+	for i, s := range p.settings {
+		cursor := "  "
+		if i == p.cursor {
+			cursor = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%-*s  ‹ %s ›", cursor, labelWidth, s.label, s.values[s.index]))
+	}
+	lines = append(lines, "", p.theme.Controls.Render("[↑/↓] select | [←/→] change | [esc] back"))
+	return strings.Join(lines, "\n")
+}
+
+// onOff returns the index of a setting's "on" value if on, for settings
+// listed as off, on
+func onOff(on bool) int {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+// openSettings opens the settings panel with the settings that apply to
+// this session. Playback carries on so changes show right away.
+func (m *Model) openSettings() {
+	var settings []setting
+	if !sshMode && audioFile != "" {
+		// Over SSH audio would play on the server
+		settings = append(settings, setting{
+			id: settingAudio, label: "Audio", values: []string{"off", "on"},
+			index: onOff(m.audioEnabled),
+		})
+	}
+	settings = append(settings, setting{
+		id: settingSubtitles, label: "Subtitles",
+		values: []string{subtitleLanguageName(0), subtitleLanguageName(1), subtitleLanguageName(2)},
+		index:  m.subtitleMode,
+	})
+	if m.graphics == "" && !m.noVideo {
+		// Graphics protocols are picked up front, only text modes switch
+		settings = append(settings, setting{
+			id: settingColor, label: "Color mode", values: colorModes,
+			index: colorModeIndex(m.halfblock, m.subpixel),
+		})
+	}
+	if len(m.speedRamp) > 0 {
+		settings = append(settings, setting{
+			id: settingSpeedRamp, label: "Speed ramp", values: []string{"off", "on"},
+			index: onOff(!m.rampOff),
+		})
+	}
+	if !sshMode && audioFile != "" {
+		settings = append(settings, setting{
+			id: settingVolume, label: "Volume", values: volumeChoices,
+			index: int(m.volume*10 + 0.5),
+		})
+	}
+	settings = append(settings, setting{
+		id: settingFPS, label: "FPS", values: fpsChoices,
+		index: m.frameStep - 1,
+	})
+	m.settings = newSettingsPanel(settings, m.theme)
+}
+
+// applySetting applies a change from the settings panel
+func (m *Model) applySetting(msg settingChangedMsg) tea.Cmd {
+	switch msg.id {
+	case settingAudio:
+		m.audioEnabled = msg.index == 1
+		if !m.audioEnabled {
+			m.stopAudio()
+		}
+		if m.audioEnabled && m.audioPlayer == nil && m.frameCount > 0 {
+			return m.startAudio()
+		}
+	case settingSubtitles:
+		m.subtitleMode = msg.index
+		m.currentCues = nil
+		m.updateSubtitle()
+		return m.layout()
+	case settingColor:
+		m.halfblock = msg.index == 1
+		m.subpixel = msg.index == 2
+		// Force a reload even if the video size is unchanged
+		m.videoWidth = 0
+		return m.layout()
+	case settingSpeedRamp:
+		m.rampOff = msg.index == 0
+		m.muteForSpeed()
+	case settingVolume:
+		m.volume = float64(msg.index) / 10
+		if m.audioPlayer != nil {
+			m.audioPlayer.SetVolume(m.volume)
+		}
+	case settingFPS:
+		m.frameStep = msg.index + 1
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"senshukai/render"
+)
+
+// shotShades is how much of a cell each ASCII shade covers, for drawing
+// rendered frames as images
+var shotShades = map[rune]float64{
+	'█': 1,
+	'▓': 0.75,
+	'▒': 0.5,
+	'░': 0.25,
+}
+
+// parseClock parses a time like 1:23, 1:23.5, 1:02:03 or plain seconds
+func parseClock(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q (want [h:]mm:ss)", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid time %q (want [h:]mm:ss)", s)
+	}
+	total := time.Duration(seconds * float64(time.Second))
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q (want [h:]mm:ss)", s)
+		}
+		total += time.Duration(n) * unit
+		unit *= 60
+	}
+	return total, nil
+}
+
+// writeShot renders the frame shown at the given time of the video as ASCII
+// cols wide and writes it to path as a PNG, each cell drawn as a terminal
+// would: light shades on a black background
+func writeShot(path string, at time.Duration, cols, frameCount int) error {
+	index := frameAt(at)
+	if index >= frameCount {
+		return fmt.Errorf("%s is past the end of the video", formatTimestamp(at))
+	}
+	// loadFrame counts from the start of the clip
+	img, err := loadFrame(index-(clipFrom-1)+1, backgroundColor)
+	if err != nil {
+		return err
+	}
+	if !cropRect.Empty() {
+		img = cropGray(img, cropRect)
+	}
+	b := img.Bounds()
+	cols, rows := containSize(b.Dx(), b.Dy(), cols, cols*cellPixelHeight)
+
+	shot := image.NewGray(image.Rect(0, 0, cols*cellPixelWidth, rows*cellPixelHeight))
+	for y, line := range render.Blocks(img, cols, rows, false) {
+		for x, r := range []rune(line) {
+			level := uint8(shotShades[r] * 255)
+			for py := y * cellPixelHeight; py < (y+1)*cellPixelHeight; py++ {
+				row := shot.Pix[py*shot.Stride:]
+				for px := x * cellPixelWidth; px < (x+1)*cellPixelWidth; px++ {
+					row[px] = level
+				}
+			}
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, shot); err != nil {
+		file.Close()
+		return fmt.Errorf("error encoding screenshot: %w", err)
+	}
+	return file.Close()
+}
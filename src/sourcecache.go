@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"sync"
+)
+
+// sourceCacheMB is the -source-cache budget for decoded frames, in MB
+var sourceCacheMB = 256
+
+// sourceCache keeps decoded frames of the clip, after -bg and -crop, so a
+// resize re-renders them at the new size without reading their files again
+var sourceCache imageCache
+
+// imageCache holds decoded frames by their number in the clip, up to a byte
+// limit. Frames are re-rendered from the first one on, so once full it keeps
+// what it has rather than evicting: the start of the clip, which a re-render
+// needs first, stays cached.
+type imageCache struct {
+	mu     sync.Mutex
+	images map[int]*image.Gray
+	size   int64 // bytes of pixels held
+	limit  int64
+}
+
+// setLimit sets the cache size in bytes, 0 to cache nothing, and empties it
+func (c *imageCache) setLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = limit
+	c.images, c.size = nil, 0
+}
+
+// get returns a cached frame, or nil. The image is shared and must not be
+// changed.
+func (c *imageCache) get(frameNum int) *image.Gray {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.images[frameNum]
+}
+
+// put caches a frame if it fits
+func (c *imageCache) put(frameNum int, img *image.Gray) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size := int64(len(img.Pix))
+	if c.size+size > c.limit || c.images[frameNum] != nil {
+		return
+	}
+	if c.images == nil {
+		c.images = make(map[int]*image.Gray)
+	}
+	c.images[frameNum] = img
+	c.size += size
+}
+
+// reset empties the cache, for when the frames being played change
+func (c *imageCache) reset() {
+	c.setLimit(c.limit)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// speedPoint sets the playback speed from a point in the video onwards
+type speedPoint struct {
+	at    time.Duration
+	speed float64
+}
+
+// parseSpeedRamp parses "seconds:speed" pairs like "0:1.0,60:0.25,120:1.0"
+func parseSpeedRamp(s string) ([]speedPoint, error) {
+	var ramp []speedPoint
+	for _, pair := range strings.Split(s, ",") {
+		at, speed, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		seconds, err := strconv.ParseFloat(at, 64)
+		if !ok || err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid speed ramp %q (want seconds:speed,...)", s)
+		}
+		factor, err := strconv.ParseFloat(speed, 64)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("invalid speed %q in speed ramp (must be positive)", speed)
+		}
+		ramp = append(ramp, speedPoint{at: time.Duration(seconds * float64(time.Second)), speed: factor})
+	}
+	sort.Slice(ramp, func(i, j int) bool { return ramp[i].at < ramp[j].at })
+	return ramp, nil
+}
+
+// speedAt returns the playback speed at t, stepping at each point of the
+// ramp. Before the first point the video plays at normal speed.
+func speedAt(ramp []speedPoint, t time.Duration) float64 {
+	// Index of the first point after t
+	i := sort.Search(len(ramp), func(i int) bool { return ramp[i].at > t })
+	if i == 0 {
+		return 1
+	}
+	return ramp[i-1].speed
+}
@@ -4,37 +4,120 @@ import (
 	"bufio"
 	"embed"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/charmbracelet/log"
 )
 
 //go:embed bad_apple_*.srt
 var subtitleFiles embed.FS
 
+// subtitleTracks maps language codes to the embedded subtitle files
+var subtitleTracks = map[string]string{
+	"ja": "bad_apple_ja.srt",
+	"en": "bad_apple_en.srt",
+}
+
+// subtitleOverrides maps language codes to subtitle files on disk that
+// replace the embedded track, set by -subs-ja, -subs-en and -playlist. An
+// empty path leaves the language without a track.
+var subtitleOverrides = map[string]string{}
+
 // Subtitle represents a single subtitle entry
 type Subtitle struct {
 	ID        int
 	StartTime time.Duration
 	EndTime   time.Duration
 	Text      string
+
+	// Latest EndTime of this and all earlier cues, so overlapping tracks can
+	// still be binary searched. Set by indexSubtitles.
+	maxEnd time.Duration
+}
+
+// Active reports whether the cue is shown at the given time. Both ends are
+// inclusive.
+func (s Subtitle) Active(at time.Duration) bool {
+	return at >= s.StartTime && at <= s.EndTime
+}
+
+// indexSubtitles prepares cues sorted by start time for findSubtitle
+func indexSubtitles(subs []Subtitle) {
+	var maxEnd time.Duration
+	for i := range subs {
+		maxEnd = max(maxEnd, subs[i].EndTime)
+		subs[i].maxEnd = maxEnd
+	}
 }
 
-// ParseSRT parses an SRT file and returns a slice of Subtitle objects
+// findSubtitle returns the index of the first cue active at the given time,
+// or -1. Cues must be sorted by start time and indexed with indexSubtitles.
+func findSubtitle(subs []Subtitle, at time.Duration) int {
+	// Every cue before i ended before the given time
+	i := sort.Search(len(subs), func(i int) bool {
+		return subs[i].maxEnd >= at
+	})
+	if i < len(subs) && subs[i].Active(at) {
+		return i
+	}
+	return -1
+}
+
+// findSubtitleNear is like findSubtitle but first checks the hinted cue and
+// the one after it, which is the common case during forward playback
+func findSubtitleNear(subs []Subtitle, at time.Duration, hint int) int {
+	for i := hint; i >= 0 && i < len(subs) && i <= hint+1; i++ {
+		// Only take the hint if no earlier cue is also active
+		if subs[i].Active(at) && (i == 0 || subs[i-1].maxEnd < at) {
+			return i
+		}
+	}
+	return findSubtitle(subs, at)
+}
+
+// Cues outside this duration range are too coarse to interpolate progress over
+const (
+	minProgressCue = 300 * time.Millisecond
+	maxProgressCue = 10 * time.Second
+)
+
+// Progress returns how far through the cue the given time is, from 0 to 1.
+// It returns -1 when the cue's timing is too coarse to interpolate.
+func (s Subtitle) Progress(at time.Duration) float64 {
+	d := s.EndTime - s.StartTime
+	if d < minProgressCue || d > maxProgressCue {
+		return -1
+	}
+	p := float64(at-s.StartTime) / float64(d)
+	return max(0, min(p, 1))
+}
+
+// ParseSRT parses an embedded SRT file and returns a slice of Subtitle objects
 func ParseSRT(filename string) ([]Subtitle, error) {
 	file, err := subtitleFiles.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("could not open srt file: %w", err)
 	}
 	defer file.Close()
+	return parseSRT(file)
+}
 
+// parseSRT parses SRT cues from r
+func parseSRT(r io.Reader) ([]Subtitle, error) {
 	var subtitles []Subtitle
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	var current Subtitle
 	var step int
 
 	for scanner.Scan() {
-		line := scanner.Text()
+		// Files saved on Windows end lines in \r\n and may start with a BOM
+		line := strings.TrimSuffix(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r")
 
 		switch step {
 		case 0:
@@ -46,8 +129,13 @@ func ParseSRT(filename string) ([]Subtitle, error) {
 		case 1:
 			parts := strings.Split(line, " --> ")
 			if len(parts) == 2 {
-				current.StartTime, _ = parseSRTTime(parts[0])
-				current.EndTime, _ = parseSRTTime(parts[1])
+				var err error
+				if current.StartTime, err = parseSRTTime(parts[0]); err != nil {
+					return nil, fmt.Errorf("cue %d: %w", current.ID, err)
+				}
+				if current.EndTime, err = parseSRTTime(parts[1]); err != nil {
+					return nil, fmt.Errorf("cue %d: %w", current.ID, err)
+				}
 				step++
 			}
 		case 2:
@@ -71,25 +159,167 @@ func ParseSRT(filename string) ([]Subtitle, error) {
 		return nil, fmt.Errorf("error reading srt file: %w", err)
 	}
 
+	// Hand-edited files can have cues out of order, which lookups can't skip
+	byStart := func(i, j int) bool { return subtitles[i].StartTime < subtitles[j].StartTime }
+	if !sort.SliceIsSorted(subtitles, byStart) {
+		log.Warn("subtitle cues are out of time order, sorting them by start time", "cues", len(subtitles))
+		sort.SliceStable(subtitles, byStart)
+	}
+	indexSubtitles(subtitles)
+
 	return subtitles, nil
 }
 
 // parseSRTTime parses the time format from an SRT file
 func parseSRTTime(s string) (time.Duration, error) {
 	// 00:00:29,082
-	parts := strings.Split(s, ":")
+	parts := strings.Split(strings.TrimSpace(s), ":")
 	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid time format")
+		return 0, fmt.Errorf("invalid time %q (want hh:mm:ss,mmm)", s)
 	}
-
-	h, _ := strconv.Atoi(parts[0])
-	m, _ := strconv.Atoi(parts[1])
 	secMs := strings.Split(parts[2], ",")
-	sec, _ := strconv.Atoi(secMs[0])
-	ms, _ := strconv.Atoi(secMs[1])
+	if len(secMs) != 2 {
+		return 0, fmt.Errorf("invalid time %q (want hh:mm:ss,mmm)", s)
+	}
+
+	var fields [4]int
+	for i, field := range []string{parts[0], parts[1], secMs[0], secMs[1]} {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q (want hh:mm:ss,mmm)", s)
+		}
+		fields[i] = n
+	}
+	h, m, sec, ms := fields[0], fields[1], fields[2], fields[3]
 
 	return time.Hour*time.Duration(h) +
 		time.Minute*time.Duration(m) +
 		time.Second*time.Duration(sec) +
 		time.Millisecond*time.Duration(ms), nil
 }
+
+// formatTimestamp formats a duration as m:ss for display
+func formatTimestamp(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// maxSubtitleLines returns the most lines shown at once, counting
+// overlapping cues stacked on top of each other and reading rows if ruby
+func maxSubtitleLines(subs []Subtitle, ruby bool) int {
+	most := 1
+	// The tallest stack always starts when some cue starts
+	for _, sub := range subs {
+		lines := 0
+		for _, other := range subs {
+			if other.Active(sub.StartTime) {
+				lines += rubyLines(other.Text, ruby)
+			}
+		}
+		most = max(most, lines)
+	}
+	return most
+}
+
+// formatSRTTime formats a duration in the SRT time format
+func formatSRTTime(d time.Duration) string {
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	s := d % time.Minute / time.Second
+	ms := d % time.Second / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+// writeTranscript writes cues in SRT form, or just their text if plain
+func writeTranscript(w io.Writer, subs []Subtitle, plain bool) error {
+	for i, sub := range subs {
+		var err error
+		if plain {
+			_, err = fmt.Fprintln(w, sub.Text)
+		} else {
+			_, err = fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+				i+1, formatSRTTime(sub.StartTime), formatSRTTime(sub.EndTime), sub.Text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSubtitles loads the subtitle track for a language, from its override
+// file if one was given
+func loadSubtitles(lang string) ([]Subtitle, error) {
+	lang = strings.ToLower(lang)
+	if path, ok := subtitleOverrides[lang]; ok {
+		if path == "" {
+			return nil, nil
+		}
+		return parseSubtitleFile(path)
+	}
+	filename, ok := subtitleTracks[lang]
+	if !ok {
+		return nil, fmt.Errorf("unknown subtitle language %q (want ja or en)", lang)
+	}
+	return ParseSRT(filename)
+}
+
+// parseSubtitleFile parses a subtitle file on disk, picking the format by
+// its extension
+func parseSubtitleFile(path string) ([]Subtitle, error) {
+	var parse func(io.Reader) ([]Subtitle, error)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		parse = parseSRT
+	case ".ass", ".ssa":
+		parse = ParseASS
+	default:
+		return nil, fmt.Errorf("unsupported subtitle format %q (want .srt, .ass or .ssa)", filepath.Ext(path))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open subtitle file: %w", err)
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+// printTranscript prints the subtitle track for a language to stdout, with
+// -sub-offset applied
+func printTranscript(lang string, plain bool) error {
+	subs, err := loadSubtitles(lang)
+	if err != nil {
+		return err
+	}
+	return writeTranscript(os.Stdout, shiftSubtitles(subs, subtitleOffset), plain)
+}
+
+// shiftSubtitles returns cues moved later by offset, or earlier if it is
+// negative. Cues shifted to before the start are clipped to it, or dropped
+// if they end there.
+func shiftSubtitles(subs []Subtitle, offset time.Duration) []Subtitle {
+	shifted := make([]Subtitle, 0, len(subs))
+	for _, sub := range subs {
+		sub.StartTime = max(0, sub.StartTime+offset)
+		sub.EndTime += offset
+		if sub.EndTime <= 0 {
+			continue
+		}
+		shifted = append(shifted, sub)
+	}
+	return shifted
+}
+
+// exportSubtitles writes cues as an SRT file with offset baked into their
+// times
+func exportSubtitles(path string, subs []Subtitle, offset time.Duration) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeTranscript(file, shiftSubtitles(subs, offset), false); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// cue returns a cue from start to end seconds
+func cue(id int, start, end float64) Subtitle {
+	return Subtitle{
+		ID:        id,
+		StartTime: time.Duration(start * float64(time.Second)),
+		EndTime:   time.Duration(end * float64(time.Second)),
+	}
+}
+
+func TestSubtitleActiveBoundaries(t *testing.T) {
+	// Both ends are inclusive, a cue shows on its first and last instant
+	s := cue(1, 1, 2)
+	for _, tt := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{time.Second - 1, false},
+		{time.Second, true},
+		{1500 * time.Millisecond, true},
+		{2 * time.Second, true},
+		{2*time.Second + 1, false},
+	} {
+		if got := s.Active(tt.at); got != tt.want {
+			t.Errorf("Active(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestFindSubtitle(t *testing.T) {
+	// Gaps, cues touching end to start, and a long cue overlapping shorter
+	// ones after it
+	subs := []Subtitle{
+		cue(1, 1, 2),
+		cue(2, 2, 3),
+		cue(3, 5, 12),
+		cue(4, 6, 7),
+		cue(5, 8, 9),
+		cue(6, 14, 15),
+	}
+	indexSubtitles(subs)
+
+	// The first active cue, as a linear scan finds it
+	linear := func(at time.Duration) int {
+		for i, s := range subs {
+			if s.Active(at) {
+				return i
+			}
+		}
+		return -1
+	}
+	for at := time.Duration(0); at <= 16*time.Second; at += 250 * time.Millisecond {
+		want := linear(at)
+		if got := findSubtitle(subs, at); got != want {
+			t.Errorf("findSubtitle(%v) = %d, want %d", at, got, want)
+		}
+		// Every hint gives the same answer, right or wrong
+		for hint := -1; hint <= len(subs); hint++ {
+			if got := findSubtitleNear(subs, at, hint); got != want {
+				t.Errorf("findSubtitleNear(%v, %d) = %d, want %d", at, hint, got, want)
+			}
+		}
+	}
+
+	if got := findSubtitle(nil, time.Second); got != -1 {
+		t.Errorf("findSubtitle with no cues = %d, want -1", got)
+	}
+}
+
+// parseLogged parses an SRT file, returning what parseSRT logged
+func parseLogged(t *testing.T, src string) ([]Subtitle, string) {
+	t.Helper()
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	subs, err := parseSRT(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subs, logged.String()
+}
+
+func TestParseSRTSortsCues(t *testing.T) {
+	shuffled := `3
+00:00:05,000 --> 00:00:06,000
+third
+
+1
+00:00:01,000 --> 00:00:02,000
+first
+
+4
+00:00:05,000 --> 00:00:07,000
+fourth
+
+2
+00:00:03,000 --> 00:00:04,000
+second
+`
+	subs, logged := parseLogged(t, shuffled)
+	// Sorted by start time, keeping the file's order for equal starts
+	var ids []int
+	for _, s := range subs {
+		ids = append(ids, s.ID)
+	}
+	if want := []int{1, 2, 3, 4}; !slices.Equal(ids, want) {
+		t.Errorf("cue order = %v, want %v", ids, want)
+	}
+	if !strings.Contains(logged, "out of time order") {
+		t.Errorf("no warning about the order, logged %q", logged)
+	}
+	if got := findSubtitle(subs, 3500*time.Millisecond); got != 1 {
+		t.Errorf("findSubtitle after sorting = %d, want 1", got)
+	}
+
+	// Cues already in order are left alone without a warning
+	if _, logged := parseLogged(t, "1\n00:00:01,000 --> 00:00:02,000\nfirst\n\n2\n00:00:03,000 --> 00:00:04,000\nsecond\n"); logged != "" {
+		t.Errorf("cues in order logged %q", logged)
+	}
+}
+
+func TestParseSRTWindowsFiles(t *testing.T) {
+	// CRLF line ends and a BOM before the first cue
+	src := "\ufeff1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,500\r\nWorld\r\n"
+	subs, _ := parseLogged(t, src)
+	if len(subs) != 2 || subs[0].ID != 1 || subs[0].Text != "Hello" || subs[1].EndTime != 4500*time.Millisecond {
+		t.Errorf("parsed %+v, want cues 1 and 2 with their text and times", subs)
+	}
+
+	for _, bad := range []string{"00:00:01.000", "00:01,000", "aa:00:01,000", "00:00:01,"} {
+		src := "1\n" + bad + " --> 00:00:02,000\ntext\n"
+		if _, err := parseSRT(strings.NewReader(src)); err == nil {
+			t.Errorf("timecode %q parsed without an error", bad)
+		}
+	}
+}
+
+func TestUpdateSubtitleOverlapping(t *testing.T) {
+	// A long cue under shorter ones that overlap each other
+	subs := []Subtitle{cue(1, 0, 10), cue(2, 1, 2), cue(3, 3, 4), cue(4, 3.5, 5)}
+	indexSubtitles(subs)
+	m := Model{subtitleMode: 1, subtitlesJA: subs}
+
+	// Forward and back again, so the last cue hint is both behind and ahead
+	for _, tt := range []struct {
+		at   float64
+		want []int
+	}{
+		{1.5, []int{1, 2}},
+		{3.75, []int{1, 3, 4}},
+		{4.5, []int{1, 4}},
+		{11, nil},
+		{3.75, []int{1, 3, 4}},
+		{1.25, []int{1, 2}},
+	} {
+		m.currentFrame = frameAt(time.Duration(tt.at * float64(time.Second)))
+		m.updateSubtitle()
+		var ids []int
+		for _, s := range m.currentCues {
+			ids = append(ids, s.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("cues at %vs = %v, want %v", tt.at, ids, tt.want)
+		}
+	}
+}
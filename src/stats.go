@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// statsSampleFrames is how often, in shown frames, memory use is sampled.
+// Reading memory stats stops the world, so it isn't done every frame.
+const statsSampleFrames = 60
+
+// playbackStats collects counters over a session for -stats-out
+type playbackStats struct {
+	start       time.Time
+	framesShown int
+	playTime    time.Duration   // time between ticks that showed a frame
+	renderTimes []time.Duration // decode and convert time of every rendered frame
+	peakHeap    uint64
+	audioStalls int
+}
+
+// rendered records the render time of loaded frames
+func (s *playbackStats) rendered(timings ...frameTiming) {
+	for _, t := range timings {
+		s.renderTimes = append(s.renderTimes, t.decode+t.convert)
+	}
+}
+
+// shown counts a frame shown interval after the previous tick, sampling
+// memory use
+func (s *playbackStats) shown(interval time.Duration) {
+	if s.framesShown%statsSampleFrames == 0 {
+		s.sampleMemory()
+	}
+	s.framesShown++
+	// The first tick after a pause spans the pause, which isn't playback
+	if interval < time.Second {
+		s.playTime += interval
+	}
+}
+
+// sampleMemory updates the peak heap size
+func (s *playbackStats) sampleMemory() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s.peakHeap = max(s.peakHeap, mem.HeapAlloc)
+}
+
+// percentile returns the p-th percentile of sorted durations in ms
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := min(len(sorted)-1, int(p/100*float64(len(sorted))))
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
+
+// writeStats writes the session's stats to path as JSON
+func writeStats(path string, s playbackStats) error {
+	s.sampleMemory()
+	runTime := time.Since(s.start)
+	fps := 0.0
+	if s.playTime > 0 {
+		fps = float64(s.framesShown) / s.playTime.Seconds()
+	}
+	sorted := slices.Clone(s.renderTimes)
+	slices.Sort(sorted)
+
+	report := struct {
+		RunTime        float64            `json:"run_time_seconds"`
+		FramesShown    int                `json:"frames_shown"`
+		FramesRendered int                `json:"frames_rendered"`
+		AverageFPS     float64            `json:"average_fps"`
+		PeakHeap       uint64             `json:"peak_heap_bytes"`
+		AudioStalls    int                `json:"audio_stalls"`
+		RenderMS       map[string]float64 `json:"render_ms"`
+	}{
+		RunTime:        runTime.Seconds(),
+		FramesShown:    s.framesShown,
+		FramesRendered: len(s.renderTimes),
+		AverageFPS:     fps,
+		PeakHeap:       s.peakHeap,
+		AudioStalls:    s.audioStalls,
+		RenderMS: map[string]float64{
+			"p50": percentile(sorted, 50),
+			"p90": percentile(sorted, 90),
+			"p99": percentile(sorted, 99),
+			"max": percentile(sorted, 100),
+		},
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing stats: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Subtitle positions relative to the video
+const (
+	subtitlePositionBottom = "bottom"
+	subtitlePositionTop    = "top"
+)
+
+// subtitleStyle controls how subtitles are drawn
+type subtitleStyle struct {
+	band     bool // draw a dim background band behind the text
+	position string
+	karaoke  bool // highlight the sung part of the line
+	ruby     bool // show kana readings over annotated kanji
+}
+
+// render centers each subtitle line within width and applies the style,
+// returning the lines without a trailing newline.
+// progress is the karaoke position through the cue, or -1 for plain display.
+func (st subtitleStyle) render(theme Theme, text string, width int, progress float64) string {
+	base := theme.Subtitle
+	if st.band {
+		base = base.Background(theme.Band)
+	}
+	highlight := theme.Highlight.Inherit(base)
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		// Trim whitespace from the line
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	// Number of tokens left to highlight, spread across all lines
+	sung := -1
+	if st.karaoke && progress >= 0 {
+		total := 0
+		for _, line := range lines {
+			total += len(karaokeTokens(line))
+		}
+		sung = int(progress * float64(total))
+	}
+
+	place := func(styled string) string {
+		if st.band {
+			// Pad with the band style so it spans the video width
+			return base.Width(width).Align(lipgloss.Center).Render(styled)
+		}
+		return lipgloss.PlaceHorizontal(width, lipgloss.Center, styled)
+	}
+
+	rows := make([]string, 0, len(lines))
+	for _, line := range lines {
+		// Readings go on their own row, padded to the same width as the
+		// bases so both center alike
+		readings, pad := "", ""
+		if st.ruby && hasRuby(line) {
+			readings, line = renderRuby(line)
+			rowWidth := max(lipgloss.Width(readings), lipgloss.Width(line))
+			readings += strings.Repeat(" ", rowWidth-lipgloss.Width(readings))
+			pad = strings.Repeat(" ", rowWidth-lipgloss.Width(line))
+			rows = append(rows, place(base.Render(readings)))
+		}
+
+		styled := base.Render(line)
+		if sung >= 0 {
+			ends := karaokeTokens(line)
+			n := min(sung, len(ends))
+			sung -= n
+			if n > 0 {
+				cut := ends[n-1]
+				styled = highlight.Render(line[:cut]) + base.Render(line[cut:])
+			}
+		}
+		rows = append(rows, place(styled+pad))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// karaokeTokens returns the byte offset at the end of each highlightable
+// token. Lines with spaces advance word by word, others (like Japanese)
+// advance rune by rune.
+func karaokeTokens(line string) []int {
+	var ends []int
+	if strings.Contains(line, " ") {
+		inWord := false
+		for i, r := range line {
+			if r == ' ' {
+				if inWord {
+					ends = append(ends, i)
+				}
+				inWord = false
+			} else {
+				inWord = true
+			}
+		}
+		if inWord {
+			ends = append(ends, len(line))
+		}
+		return ends
+	}
+	for i, r := range line {
+		ends = append(ends, i+utf8.RuneLen(r))
+	}
+	return ends
+}
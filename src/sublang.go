@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// subtitleLabels names tracks loaded with -subs whose language wasn't
+// recognized, by language code, in place of "Japanese" or "English"
+var subtitleLabels = map[string]string{}
+
+// Language codes recognized in subtitle filenames like movie.ja.srt
+var subtitleSuffixes = map[string]string{
+	"ja": "ja", "jp": "ja", "jpn": "ja", "japanese": "ja",
+	"en": "en", "eng": "en", "english": "en",
+}
+
+// detectSubtitleLanguage picks the track a subtitle file goes on: the
+// language its filename ends in, or else ja if its text has kana or other
+// CJK characters and en otherwise. known is false when the file only went
+// on the track by elimination, such as a French or Chinese file, and should
+// be labeled by its name rather than the track's language.
+func detectSubtitleLanguage(path string, subs []Subtitle) (lang string, known bool) {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if ext := filepath.Ext(stem); ext != "" {
+		if lang, ok := subtitleSuffixes[strings.ToLower(ext[1:])]; ok {
+			return lang, true
+		}
+		// Another language is named, so go by the text but keep the name
+		lang, _ = detectTextLanguage(subs)
+		return lang, false
+	}
+	return detectTextLanguage(subs)
+}
+
+// detectTextLanguage guesses a track's language from its cues. Kana only
+// appear in Japanese; Chinese and Korean text goes on the ja track too, as
+// the track for CJK text, but isn't known to be Japanese.
+func detectTextLanguage(subs []Subtitle) (lang string, known bool) {
+	var kana, cjk, letters int
+	for _, sub := range subs {
+		for _, r := range sub.Text {
+			switch {
+			case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+				kana++
+			case unicode.In(r, unicode.Han, unicode.Hangul):
+				cjk++
+			case unicode.IsLetter(r):
+				letters++
+			}
+		}
+	}
+	switch {
+	case kana > 0:
+		return "ja", true
+	case cjk > letters:
+		return "ja", false
+	}
+	return "en", true
+}
+
+// addSubtitleFile puts a -subs file on the track its language belongs on,
+// which must not have been given a file already
+func addSubtitleFile(path string) error {
+	subs, err := parseSubtitleFile(path)
+	if err != nil {
+		return err
+	}
+	lang, known := detectSubtitleLanguage(path, subs)
+	if other := subtitleOverrides[lang]; other != "" {
+		return fmt.Errorf("%s and %s both go on the %s track, give one with -subs-ja or -subs-en instead", other, path, lang)
+	}
+	subtitleOverrides[lang] = path
+	if !known {
+		subtitleLabels[lang] = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return nil
+}
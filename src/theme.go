@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme holds the styles used across the player UI
+type Theme struct {
+	Controls  lipgloss.Style // key hints
+	Status    lipgloss.Style // buffering and other status text
+	Subtitle  lipgloss.Style
+	Highlight lipgloss.Style         // karaoke text that has been sung
+	Band      lipgloss.TerminalColor // background band behind subtitles
+}
+
+// themes maps theme names to constructors. Styles are built per renderer so
+// SSH sessions get the color profile of the client's terminal.
+var themes = map[string]func(r *lipgloss.Renderer) Theme{
+	"default": func(r *lipgloss.Renderer) Theme {
+		return Theme{
+			Controls:  r.NewStyle().Faint(true),
+			Status:    r.NewStyle().Faint(true),
+			Subtitle:  r.NewStyle(),
+			Highlight: r.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "4", Dark: "3"}),
+			Band:      lipgloss.AdaptiveColor{Light: "253", Dark: "236"},
+		}
+	},
+	"mono": func(r *lipgloss.Renderer) Theme {
+		return Theme{
+			Controls:  r.NewStyle().Faint(true),
+			Status:    r.NewStyle().Faint(true),
+			Subtitle:  r.NewStyle().Bold(true),
+			Highlight: r.NewStyle().Bold(true).Underline(true),
+			Band:      lipgloss.AdaptiveColor{Light: "253", Dark: "236"},
+		}
+	},
+	"matrix-green": func(r *lipgloss.Renderer) Theme {
+		return Theme{
+			Controls:  r.NewStyle().Foreground(lipgloss.Color("28")),
+			Status:    r.NewStyle().Foreground(lipgloss.Color("28")),
+			Subtitle:  r.NewStyle().Foreground(lipgloss.Color("46")),
+			Highlight: r.NewStyle().Foreground(lipgloss.Color("156")).Bold(true),
+			Band:      lipgloss.Color("233"),
+		}
+	},
+	"amber": func(r *lipgloss.Renderer) Theme {
+		return Theme{
+			Controls:  r.NewStyle().Foreground(lipgloss.Color("130")),
+			Status:    r.NewStyle().Foreground(lipgloss.Color("130")),
+			Subtitle:  r.NewStyle().Foreground(lipgloss.Color("214")),
+			Highlight: r.NewStyle().Foreground(lipgloss.Color("228")).Bold(true),
+			Band:      lipgloss.Color("234"),
+		}
+	},
+}
+
+// themeNames returns the available theme names, sorted
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newTheme builds the named theme for a renderer, applying -sub-color
+func newTheme(name string, r *lipgloss.Renderer) Theme {
+	build, ok := themes[name]
+	if !ok {
+		build = themes["default"]
+	}
+	theme := build(r)
+	if subtitleColor != "" {
+		theme.Subtitle = theme.Subtitle.Foreground(subtitleColor)
+	}
+	return theme
+}
+
+// colorNames maps color names to their basic ANSI color indexes
+var colorNames = map[string]string{
+	"black":   "0",
+	"red":     "1",
+	"green":   "2",
+	"yellow":  "3",
+	"blue":    "4",
+	"magenta": "5",
+	"cyan":    "6",
+	"white":   "7",
+}
+
+// parseSubtitleColor converts a color name, 256-color index or #rrggbb hex
+// color to a lipgloss color
+func parseSubtitleColor(s string) (lipgloss.Color, error) {
+	if s == "" {
+		return "", nil
+	}
+	if index, ok := colorNames[strings.ToLower(s)]; ok {
+		return lipgloss.Color(index), nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 255 {
+		return lipgloss.Color(s), nil
+	}
+	if len(s) == 7 && s[0] == '#' {
+		if _, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+			return lipgloss.Color(s), nil
+		}
+	}
+	return "", fmt.Errorf("invalid subtitle color %q (want a color name, 0-255 or #rrggbb)", s)
+}
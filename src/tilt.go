@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// audioTilt is the arg for -audio-tilt: dB the treble is raised and the
+// bass lowered by, or the reverse when negative. 0 leaves audio untouched.
+var audioTilt float64
+
+// Limits and pivot of the tilt. Well above and below the pivot the sound is
+// raised or lowered by half the tilt each, crossing over around it.
+const (
+	maxAudioTilt   = 12
+	tiltPivotHertz = 800
+)
+
+// tiltFilter is a tilt equalizer over 16-bit stereo PCM: a one-pole low
+// pass splits each channel into bass and treble, which are mixed back with
+// opposite gains
+type tiltFilter struct {
+	src             io.ReadSeeker
+	coeff           float64 // low pass smoothing per sample
+	lowGain, hiGain float64
+	low             [2]float64 // low pass state per channel
+}
+
+// newTiltFilter filters src, decoded at sampleRate, by tilt dB
+func newTiltFilter(src io.ReadSeeker, tilt float64, sampleRate int) *tiltFilter {
+	return &tiltFilter{
+		src:     src,
+		coeff:   1 - math.Exp(-2*math.Pi*tiltPivotHertz/float64(sampleRate)),
+		lowGain: math.Pow(10, -tilt/40),
+		hiGain:  math.Pow(10, tilt/40),
+	}
+}
+
+// Read filters whole sample frames in place. Reads start on a frame as
+// decoding and seeking only ever move by whole frames.
+func (f *tiltFilter) Read(p []byte) (int, error) {
+	if len(p) >= audioFrameSize {
+		p = p[:len(p)/audioFrameSize*audioFrameSize]
+	}
+	n, err := f.src.Read(p)
+	for i := 0; i+audioFrameSize <= n; i += audioFrameSize {
+		for ch := range f.low {
+			at := p[i+2*ch:]
+			x := float64(int16(binary.LittleEndian.Uint16(at)))
+			f.low[ch] += f.coeff * (x - f.low[ch])
+			y := f.low[ch]*f.lowGain + (x-f.low[ch])*f.hiGain
+			y = max(math.MinInt16, min(math.MaxInt16, math.Round(y)))
+			binary.LittleEndian.PutUint16(at, uint16(int16(y)))
+		}
+	}
+	return n, err
+}
+
+// Seek moves the source, starting the filter afresh
+func (f *tiltFilter) Seek(offset int64, whence int) (int64, error) {
+	f.low = [2]float64{}
+	return f.src.Seek(offset, whence)
+}
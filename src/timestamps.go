@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frameTimestampsFile, in the frames directory, lists the presentation time
+// of every frame in seconds, one per line, for variable frame rate sources.
+// ffprobe writes one with -show_entries frame=pts_time -of csv=p=0.
+const frameTimestampsFile = "timestamps.txt"
+
+// frameTimestamps holds the presentation time of every frame when frames/
+// has timestamps, or nil to play at a constant frameDuration
+var frameTimestamps []time.Duration
+
+// loadFrameTimestamps reads frame times in seconds, one per line. Blank
+// lines and lines starting with # are skipped.
+func loadFrameTimestamps(path string) ([]time.Duration, error) {
+	file, err := openFrameFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var times []time.Duration
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid timestamp %q", path, line, text)
+		}
+		t := time.Duration(seconds * float64(time.Second))
+		if len(times) > 0 && t < times[len(times)-1] {
+			return nil, fmt.Errorf("%s:%d: timestamp %q is before the previous frame", path, line, text)
+		}
+		times = append(times, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading frame timestamps: %w", err)
+	}
+	return times, nil
+}
+
+// frameTime returns when frame i of the full video is shown. Past the last
+// timestamp, frames follow on at the last frame's length.
+func frameTime(i int) time.Duration {
+	n := len(frameTimestamps)
+	switch {
+	case n == 0:
+		return time.Duration(i) * frameDuration
+	case i < 0:
+		return 0
+	case i >= n:
+		return frameTimestamps[n-1] + time.Duration(i-n+1)*frameLength(n-1)
+	}
+	return frameTimestamps[i]
+}
+
+// frameLength returns how long frame i of the full video is shown
+func frameLength(i int) time.Duration {
+	if i < 0 || i+1 >= len(frameTimestamps) {
+		return frameDuration
+	}
+	return frameTimestamps[i+1] - frameTimestamps[i]
+}
+
+// frameAt returns the frame of the full video shown at t
+func frameAt(t time.Duration) int {
+	if frameTimestamps == nil {
+		return int(t / frameDuration)
+	}
+	// The last frame starting at or before t
+	return max(0, sort.Search(len(frameTimestamps), func(i int) bool { return frameTimestamps[i] > t })-1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"senshukai/render"
+)
+
+// tweenSeparator joins a frame and its in-between frames in one string, so
+// they load, evict and re-render together. Rendered frames never contain it.
+const tweenSeparator = "\x00"
+
+// tweenSteps returns how many display ticks frame i of the full video spans,
+// each after the first showing an in-between frame
+func tweenSteps(i int) int {
+	return max(1, int(math.Round(float64(frameLength(i))/float64(displayInterval))))
+}
+
+// withTweens appends in-between frames to the rendered frame, img, fading
+// into the next frame of the clip. The last frame of the clip has none.
+func withTweens(frame string, img *image.Gray, frameNum int, opts renderOptions) (string, error) {
+	steps := tweenSteps(clipFrom - 1 + frameNum - 1)
+	if steps == 1 || clipFrom-1+frameNum >= clipTo {
+		return frame, nil
+	}
+	next, err := sourceFrame(frameNum+1, opts)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{frame}
+	for j := 1; j < steps; j++ {
+		tween := image.NewGray(img.Bounds())
+		copy(tween.Pix, img.Pix)
+		render.Blend(tween, next, float64(j)/float64(steps))
+		rendered, err := renderImage(tween, opts)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, rendered)
+	}
+	return strings.Join(parts, tweenSeparator), nil
+}
+
+// tweens returns how many in-between frames a loaded frame carries
+func tweens(frame string) int {
+	return strings.Count(frame, tweenSeparator)
+}
+
+// tweenFrame returns the j-th display frame of a loaded frame: the frame
+// itself for 0, then its in-between frames. Past the last one it holds it.
+func tweenFrame(frame string, j int) string {
+	if j == 0 {
+		frame, _, _ = strings.Cut(frame, tweenSeparator)
+		return frame
+	}
+	parts := strings.Split(frame, tweenSeparator)
+	return parts[min(j, len(parts)-1)]
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// flagGroups orders the -help output, listing flags by what they're for.
+// Flags not listed here are shown under "Other" so none go missing.
+var flagGroups = []struct {
+	title string
+	flags []string
+}{
+	{"Playback", []string{"no-audio", "audio-delay", "audio-tilt", "menu", "no-menu", "once", "loop-pause", "from", "to", "repeat-frame", "script", "speed-ramp", "source-fps", "interpolate", "no-video", "beat", "keys", "playlist", "crossfade"}},
+	{"Frames", []string{"pack", "frames-archive", "frames-url", "frames-sha256", "frame-pattern", "frame-digits", "frame-start", "crop", "bg", "gray-weights", "prerendered"}},
+	{"Display", []string{"graphics", "ascii-safe", "no-ascii-safe", "halfblock", "subpixel", "crt", "crt-green", "color-threshold", "fit", "integer-scale", "border", "border-title", "term-bg", "ansi", "output-fd", "theme"}},
+	{"Subtitles", []string{"subs", "subs-ja", "subs-en", "sub-offset", "export-subs", "sub-color", "sub-bg", "sub-position", "karaoke", "subtitle-font-hint"}},
+	{"Memory", []string{"prefetch", "buffer", "source-cache", "max-memory", "sync-load"}},
+	{"SSH server", []string{"ssh", "ssh-record", "ssh-record-limit", "ssh-prefs", "ssh-idle-timeout"}},
+	{"Output and exit", []string{"transcript", "transcript-plain", "shot", "at", "shot-width", "palette-preview", "info", "info-json", "check-sync", "diff", "diff-to", "diff-threshold", "diff-csv", "headless-render", "render-width", "render-height"}},
+	{"Logging and profiling", []string{"silent-output", "log-json", "log-level", "stats-out", "cpuprofile", "memprofile"}},
+}
+
+// flagAliases maps old flag names to the flag they're kept as an alias for.
+// Aliases are shown beside their flag instead of on their own.
+var flagAliases = map[string]string{
+	"q": "no-audio",
+}
+
+// usage prints the flags by group, replacing the flag package's flat
+// alphabetical list
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\nPlays bad apple in the shell, or serves it over SSH with -ssh.\n", flag.CommandLine.Name())
+	fmt.Fprintf(out, "\nCommands:\n")
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "  %s %s\n    \t%s\n", c.name, c.args, c.desc)
+	}
+
+	aliases := map[string][]string{}
+	for alias, name := range flagAliases {
+		aliases[name] = append(aliases[name], alias)
+	}
+	listed := map[string]bool{}
+	for _, group := range flagGroups {
+		fmt.Fprintf(out, "\n%s:\n", group.title)
+		for _, name := range group.flags {
+			if f := flag.Lookup(name); f != nil {
+				printFlag(out, f, aliases[name])
+				listed[name] = true
+			}
+		}
+	}
+
+	var other []*flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, alias := flagAliases[f.Name]; !listed[f.Name] && !alias {
+			other = append(other, f)
+		}
+	})
+	if len(other) > 0 {
+		fmt.Fprintf(out, "\nOther:\n")
+		for _, f := range other {
+			printFlag(out, f, nil)
+		}
+	}
+}
+
+// printFlag prints a flag's names, argument and description, with its
+// default if it has one worth showing
+func printFlag(out io.Writer, f *flag.Flag, aliases []string) {
+	arg, desc := flag.UnquoteUsage(f)
+	names := "-" + f.Name
+	for _, alias := range aliases {
+		names += ", -" + alias
+	}
+	if arg != "" {
+		names += " " + arg
+	}
+	switch f.DefValue {
+	case "", "0", "0s", "false":
+	default:
+		if !strings.Contains(desc, "(default") {
+			desc += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+	}
+	fmt.Fprintf(out, "  %s\n    \t%s\n", names, desc)
+}
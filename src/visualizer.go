@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// visualizerGain scales RMS levels up to bar heights. Music rarely gets
+// close to full scale RMS, so unscaled bars would barely move.
+const visualizerGain = 3
+
+// Partial blocks for the top of a bar, in eighths of a cell
+var barRunes = []rune(" ▁▂▃▄▅▆▇█")
+
+// renderVisualizer draws one vertical bar per level, filling a width x
+// height area
+func renderVisualizer(levels []float64, width, height int) string {
+	// Bar heights in eighths of a cell
+	heights := make([]int, width)
+	for x := range heights {
+		if x < len(levels) {
+			heights[x] = min(height*8, int(levels[x]*visualizerGain*float64(height*8)))
+		}
+	}
+
+	lines := make([]string, height)
+	for y := range lines {
+		// Eighths of a cell below this row
+		floor := (height - 1 - y) * 8
+		var sb strings.Builder
+		for _, h := range heights {
+			sb.WriteRune(barRunes[max(0, min(8, h-floor))])
+		}
+		lines[y] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}